@@ -0,0 +1,350 @@
+package optizz
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Authenticator authenticates the requests for a security
+// scheme. It returns the authenticated principal, or an
+// error if the credentials are missing or invalid. The
+// scopes are those required by the operation, for the
+// oauth2 and openIdConnect schemes.
+type Authenticator interface {
+	Authenticate(c *fiber.Ctx, scheme *SecurityScheme, scopes []string) (interface{}, error)
+}
+
+// AuthenticatorFunc is an adapter to use
+// a func as an Authenticator.
+type AuthenticatorFunc func(c *fiber.Ctx, scheme *SecurityScheme, scopes []string) (interface{}, error)
+
+// Authenticate calls f(c, scheme, scopes).
+func (f AuthenticatorFunc) Authenticate(c *fiber.Ctx, scheme *SecurityScheme, scopes []string) (interface{}, error) {
+	return f(c, scheme, scopes)
+}
+
+// APIKeyVerifier verifies an API key and returns
+// the principal it belongs to.
+type APIKeyVerifier func(c *fiber.Ctx, key string) (interface{}, error)
+
+// TokenVerifier verifies a bearer token, such as a JWT, and
+// its scopes, and returns the principal it was issued to.
+type TokenVerifier func(c *fiber.Ctx, token string, scopes []string) (interface{}, error)
+
+// BasicVerifier verifies the credentials of the HTTP basic
+// authentication and returns the principal they belong to.
+type BasicVerifier func(c *fiber.Ctx, username, password string) (interface{}, error)
+
+// errMissingCredentials is the error of the builtin
+// authenticators for the requests without credentials.
+var errMissingCredentials = errors.New("missing credentials")
+
+// APIKeyAuthenticator returns an authenticator for apiKey schemes,
+// which reads the key from the query parameter, header or cookie
+// of the scheme and verifies it with verify.
+func APIKeyAuthenticator(verify APIKeyVerifier) Authenticator {
+	return AuthenticatorFunc(func(c *fiber.Ctx, scheme *SecurityScheme, _ []string) (interface{}, error) {
+		var key string
+		switch scheme.In {
+		case QueryTag:
+			key = c.Query(scheme.Name)
+		case HeaderTag:
+			key = c.Get(scheme.Name)
+		case CookieTag:
+			key = c.Cookies(scheme.Name)
+		default:
+			return nil, fmt.Errorf("invalid location of the API key: %q", scheme.In)
+		}
+		if key == "" {
+			return nil, errMissingCredentials
+		}
+		return verify(c, key)
+	})
+}
+
+// BearerAuthenticator returns an authenticator for http bearer,
+// oauth2 and openIdConnect schemes, which reads the bearer token
+// from the Authorization header and verifies it with verify.
+func BearerAuthenticator(verify TokenVerifier) Authenticator {
+	return AuthenticatorFunc(func(c *fiber.Ctx, _ *SecurityScheme, scopes []string) (interface{}, error) {
+		token, ok := authorization(c, "Bearer")
+		if !ok {
+			return nil, errMissingCredentials
+		}
+		return verify(c, token, scopes)
+	})
+}
+
+// BasicAuthenticator returns an authenticator for http basic
+// schemes, which reads the credentials from the Authorization
+// header and verifies them with verify.
+func BasicAuthenticator(verify BasicVerifier) Authenticator {
+	return AuthenticatorFunc(func(c *fiber.Ctx, _ *SecurityScheme, _ []string) (interface{}, error) {
+		credentials, ok := authorization(c, "Basic")
+		if !ok {
+			return nil, errMissingCredentials
+		}
+		b, err := base64.StdEncoding.DecodeString(credentials)
+		if err != nil {
+			return nil, errors.New("malformed basic credentials")
+		}
+		i := strings.IndexByte(string(b), ':')
+		if i < 0 {
+			return nil, errors.New("malformed basic credentials")
+		}
+		return verify(c, string(b[:i]), string(b[i+1:]))
+	})
+}
+
+// authorization returns the credentials of the Authorization
+// header of the request for the given scheme.
+func authorization(c *fiber.Ctx, scheme string) (string, bool) {
+	h := c.Get(fiber.HeaderAuthorization)
+	if len(h) <= len(scheme) || !strings.EqualFold(h[:len(scheme)], scheme) || h[len(scheme)] != ' ' {
+		return "", false
+	}
+	credentials := strings.TrimSpace(h[len(scheme)+1:])
+	return credentials, credentials != ""
+}
+
+// authentication holds the authenticators set with
+// Authenticate, which the routes of the instance enforce.
+type authentication struct {
+	mu             sync.RWMutex
+	authenticators map[string]Authenticator
+
+	// parent is the instance the instance is mounted
+	// on, whose authenticators apply if it has none.
+	parent *Optizz
+}
+
+// authenticatorsOf returns the authenticators that apply
+// to the routes of the instance, and the instance that
+// declares the security schemes they authenticate.
+func (f *Optizz) authenticatorsOf() (map[string]Authenticator, *Optizz) {
+	for inst := f; inst != nil; {
+		inst.auth.mu.RLock()
+		authenticators, parent := inst.auth.authenticators, inst.auth.parent
+		inst.auth.mu.RUnlock()
+		if authenticators != nil {
+			return authenticators, inst
+		}
+		inst = parent
+	}
+	return nil, nil
+}
+
+// configError is the error of a security requirement
+// that cannot be enforced, such as a scheme that has
+// no authenticator. It is a failure of the server
+// rather than of the credentials of the client.
+type configError struct {
+	message string
+}
+
+func (e configError) Error() string {
+	return e.message
+}
+
+// Authenticate sets the authenticators of the security schemes,
+// by name, and returns a middleware that enforces the security
+// requirements of the operations of the specification. A request
+// is authenticated if it satisfies all the schemes of one of the
+// requirements of its operation. The operations without
+// requirements are not protected.
+//
+// Once Authenticate is called, the routes registered with the
+// handlers of the instance, and of the instances mounted on it,
+// enforce their requirements themselves, whether the middleware
+// is used or not, so that no request reaches them
+// unauthenticated. Until then, the requirements are only
+// documented, for the apps that authenticate the requests
+// on their own, such as behind a gateway. The middleware protects the routes registered on the Fiber
+// app directly, as long as their operation is documented.
+//
+// The requests that are not authenticated are rejected with
+// the error returned by an authenticator if it is an HTTPError,
+// or with a 401, rendered with the hooks of the route. The
+// requirements of an unknown scheme, or of a scheme without
+// authenticator, fail with a 500, and their details are logged.
+func (f *Optizz) Authenticate(authenticators map[string]Authenticator) fiber.Handler {
+	f.auth.mu.Lock()
+	f.auth.authenticators = make(map[string]Authenticator, len(authenticators))
+	for name, a := range authenticators {
+		f.auth.authenticators[name] = a
+	}
+	f.auth.mu.Unlock()
+
+	return func(c *fiber.Ctx) error {
+		reqs, ok := f.documentedSecurity(c)
+		if !ok {
+			return c.Next()
+		}
+		return f.enforce(c, f.RouterGroup.scope, reqs)
+	}
+}
+
+// documentedSecurity returns the security requirements of the
+// operation of the specification that matches the request, if
+// it is not served by a route of the registry, which enforces
+// them itself.
+func (f *Optizz) documentedSecurity(c *fiber.Ctx) ([]SecurityRequirement, bool) {
+	method := c.Method()
+	op, _ := f.lookupOperation(method, c.Path())
+	if op == nil && method == fiber.MethodHead {
+		// Fiber registers the GET routes for HEAD too.
+		method = fiber.MethodGet
+		op, _ = f.lookupOperation(method, c.Path())
+	}
+	if op == nil {
+		return nil, false
+	}
+	key, ok := operationKeyOf(f.gen.API(), op)
+	if !ok {
+		return nil, false
+	}
+	f.registry.mu.RLock()
+	_, served := f.registry.byPath[routeKey(method, fiberPath(key.path))]
+	f.registry.mu.RUnlock()
+	if served {
+		return nil, false
+	}
+	return f.specExt.security[key], true
+}
+
+// guard returns the handler of a route that enforces the
+// security requirements returned by security, resolved for
+// each request so that the requirements set on the groups
+// after the route was registered apply. The errors are
+// rendered with the hooks of the scope s.
+func (f *Optizz) guard(s *scope, security func() []SecurityRequirement) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		return f.enforce(c, s, security())
+	}
+}
+
+// enforce authenticates the request with one of the security
+// requirements reqs, and calls the next handler if it is,
+// or renders the failure with the hooks of the scope s.
+// Until the authenticators are set, the requirements
+// are only documented and not enforced.
+func (f *Optizz) enforce(c *fiber.Ctx, s *scope, reqs []SecurityRequirement) error {
+	if len(reqs) == 0 {
+		return c.Next()
+	}
+	authenticators, owner := f.authenticatorsOf()
+	if authenticators == nil {
+		return c.Next()
+	}
+	var failure, misconfig error
+	for _, req := range reqs {
+		principals, err := owner.authenticate(c, authenticators, req)
+		if err == nil {
+			c.Locals(ctxPrincipals, principals)
+			return c.Next()
+		}
+		var (
+			he *HTTPError
+			ce configError
+		)
+		switch {
+		case errors.As(err, &ce):
+			if misconfig == nil {
+				s.log().Printf("optizz: security requirement of %s %s cannot be enforced: %s", c.Method(), c.Path(), ce.message)
+				misconfig = InternalServerError("").WithCode("invalid_security")
+			}
+		case failure == nil && errors.As(err, &he):
+			failure = he
+		}
+	}
+	// A requirement that cannot be enforced is not
+	// reported as a failure of the credentials.
+	if misconfig != nil {
+		failure = misconfig
+	}
+	if failure == nil {
+		failure = Unauthorized("").WithCode("unauthorized")
+		if challenge := owner.challenge(reqs); challenge != "" {
+			c.Set(fiber.HeaderWWWAuthenticate, challenge)
+		}
+	}
+	s.handleError(c, failure)
+	return nil
+}
+
+// authenticate authenticates the request with all
+// the security schemes of the requirement req.
+func (f *Optizz) authenticate(c *fiber.Ctx, authenticators map[string]Authenticator, req SecurityRequirement) ([]interface{}, error) {
+	names := make([]string, 0, len(req))
+	for name := range req {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	principals := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		scheme, ok := f.specExt.securitySchemes[name]
+		if !ok {
+			return nil, configError{message: fmt.Sprintf("unknown security scheme %q", name)}
+		}
+		auth, ok := authenticators[name]
+		if !ok {
+			return nil, configError{message: fmt.Sprintf("no authenticator for the security scheme %q", name)}
+		}
+		p, err := auth.Authenticate(c, scheme, req[name])
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, p)
+	}
+	return principals, nil
+}
+
+// challenge returns the WWW-Authenticate header of the
+// responses to the requests that are not authenticated,
+// for the http schemes of the requirements.
+func (f *Optizz) challenge(reqs []SecurityRequirement) string {
+	var challenges []string
+	for _, req := range reqs {
+		for name := range req {
+			scheme, ok := f.specExt.securitySchemes[name]
+			if !ok {
+				continue
+			}
+			var ch string
+			switch {
+			case scheme.Type == HTTPSecurity && strings.EqualFold(scheme.Scheme, "basic"):
+				ch = "Basic"
+			case scheme.Type == HTTPSecurity, scheme.Type == OAuth2Security, scheme.Type == OpenIDConnectSecurity:
+				ch = "Bearer"
+			}
+			if ch != "" && !contains(challenges, ch) {
+				challenges = append(challenges, ch)
+			}
+		}
+	}
+	sort.Strings(challenges)
+	return strings.Join(challenges, ", ")
+}
+
+// Principal returns the principal of type T authenticated by
+// the Authenticate middleware for the request of the given
+// Fiber context. If the request satisfied several schemes,
+// the first principal of type T, by name of scheme, is
+// returned.
+func Principal[T any](c *fiber.Ctx) (T, bool) {
+	principals, _ := c.Locals(ctxPrincipals).([]interface{})
+	for _, p := range principals {
+		if v, ok := p.(T); ok {
+			return v, true
+		}
+	}
+	var zero T
+	return zero, false
+}
@@ -19,11 +19,22 @@ const (
 	QueryTag      = "query"
 	PathTag       = "path"
 	HeaderTag     = "header"
+	CookieTag     = "cookie"
 	EnumTag       = "enum"
 	RequiredTag   = "required"
 	DefaultTag    = "default"
 	ValidationTag = "validate"
 	ExplodeTag    = "explode"
+	StyleTag      = "style"
+)
+
+// Styles of the query parameters, set with the style option
+// of the query tag, such as query:"filter,style=deepObject",
+// or with the style tag. The deepObject style binds nested
+// structs and maps from keys such as ?filter[owner][id]=42.
+const (
+	FormStyle       = "form"
+	DeepObjectStyle = "deepObject"
 )
 
 var (
@@ -32,6 +43,7 @@ var (
 	bindQueryHook  BindHook   = DefaultBindQueryHook
 	bindPathHook   BindHook   = DefaultBindPathHook
 	bindHeaderHook BindHook   = DefaultBindHeaderHook
+	bindCookieHook BindHook   = DefaultBindCookieHook
 	renderHook     RenderHook = DefaultRenderHook
 	execHook       ExecHook   = DefaultExecHook
 )
@@ -59,30 +71,80 @@ type ErrorHook func(*fiber.Ctx, error) (int, interface{})
 type ExecHook func(*fiber.Ctx, fiber.Handler, string) error
 
 // DefaultErrorHook is the default error hook.
-// It returns a StatusBadRequest with a payload containing
-// the error message.
+// It returns the status code and the error itself as payload
+// for an HTTPError, or a StatusBadRequest with a payload
+// containing the error message otherwise. The payload of a
+// BindError also lists its field-level errors.
 func DefaultErrorHook(c *fiber.Ctx, e error) (int, interface{}) {
+	var (
+		he *HTTPError
+		be BindError
+	)
+	if errors.As(e, &he) {
+		return he.status(), he
+	}
+	if errors.As(e, &be) {
+		if errs := be.FieldErrors(); errs != nil {
+			return http.StatusBadRequest, map[string]interface{}{
+				"error":  e.Error(),
+				"errors": errs,
+			}
+		}
+	}
 	return http.StatusBadRequest, map[string]string{
 		"error": e.Error(),
 	}
 }
 
 // DefaultBindingHook is the default binding hook.
-// It uses Fiber JSON binding to bind the body parameters of the request
-// to the input object of the handler.
-// It returns an error if Fiber binding fails.
+// It decodes the body of the request to the input object of the
+// handler with the codec registered for its media type, or uses
+// Fiber form binding for forms. The files of a multipart form
+// are bound to the fields with a form tag.
+// In strict mode, JSON bodies with unknown fields or trailing
+// data are rejected with a BindError that reports the path
+// of the offending value.
+// It returns a 415 HTTPError if the media type of the body is
+// not consumed by the operation or has no codec, and an error
+// if the decoding fails.
 func DefaultBindingHook(c *fiber.Ctx, v reflect.Value) error {
 	i := v.Interface()
 	if c.Method() == http.MethodGet || c.Request().Header.ContentLength() == 0 {
 		return nil
 	}
-
-	if err := c.BodyParser(i); err != nil && err != io.EOF {
+	mt := normalizeMediaType(string(c.Request().Header.ContentType()))
+	if !consumesMediaType(c, mt) {
+		return unsupportedMediaType(mt)
+	}
+	switch mt {
+	case multipartMediaType, fiber.MIMEApplicationForm:
+		if err := c.BodyParser(i); err != nil && err != io.EOF {
+			return fmt.Errorf("error parsing request body: %s", err.Error())
+		}
+		if mt == multipartMediaType {
+			return bindFiles(c, v)
+		}
+		return nil
+	}
+	cd, ok := codecs.lookup(mt)
+	if !ok {
+		return unsupportedMediaType(mt)
+	}
+	if strict, _ := c.Locals(ctxStrictJSON).(bool); strict && isJSONMediaType(mt) {
+		return decodeStrictJSON(c.Body(), i)
+	}
+	if err := cd.Unmarshal(c.Body(), i); err != nil && err != io.EOF {
 		return fmt.Errorf("error parsing request body: %s", err.Error())
 	}
 	return nil
 }
 
+// unsupportedMediaType returns the error of a request
+// body of a media type that cannot be decoded.
+func unsupportedMediaType(mt string) *HTTPError {
+	return NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported media type %q", mt))
+}
+
 func DefaultBindQueryHook(c *fiber.Ctx, v reflect.Value) error {
 	return bind(c, v, QueryTag, extractQuery)
 }
@@ -95,14 +157,47 @@ func DefaultBindHeaderHook(c *fiber.Ctx, v reflect.Value) error {
 	return bind(c, v, HeaderTag, extractHeader)
 }
 
+func DefaultBindCookieHook(c *fiber.Ctx, v reflect.Value) error {
+	return bind(c, v, CookieTag, extractCookie)
+}
+
+// mediaTyper is implemented by the payloads that
+// are rendered as JSON with a specific media type.
+type mediaTyper interface {
+	MediaType() string
+}
+
 // DefaultRenderHook is the default render hook.
-// It marshals the payload to JSON, or returns an empty body if the payload is nil.
+// It encodes the payload with the codec of the media type negotiated
+// with the Accept header of the request, or returns an empty body if
+// the payload is nil. Payloads that cannot be encoded with the
+// negotiated codec, such as maps in XML, are rendered as JSON.
 func DefaultRenderHook(c *fiber.Ctx, statusCode int, payload interface{}) {
-	if payload != nil {
-		c.Status(statusCode).JSON(payload)
-	} else {
+	if payload == nil {
 		c.Status(statusCode).Format("")
+		return
 	}
+	c.Vary(fiber.HeaderAccept)
+
+	mt := ResponseMediaType(c)
+	cd, ok := codecs.lookup(mt)
+	if !ok {
+		mt, cd = JSONMediaType, jsonCodec{}
+	}
+	b, err := cd.Marshal(payload)
+	if err != nil && mt != JSONMediaType {
+		mt = JSONMediaType
+		b, err = jsonCodec{}.Marshal(payload)
+	}
+	if err != nil {
+		c.Status(http.StatusInternalServerError).SendString(err.Error())
+		return
+	}
+	if t, ok := payload.(mediaTyper); ok && mt == JSONMediaType {
+		mt = t.MediaType()
+	}
+	c.Set(fiber.HeaderContentType, mt)
+	c.Status(statusCode).Send(b)
 }
 
 // DefaultExecHook is the default exec hook.
@@ -177,6 +272,19 @@ func SetBindHeaderHook(bh BindHook) {
 	}
 }
 
+// GetBindCookieHook returns the current bind cookie hook.
+func GetBindCookieHook() BindHook {
+	return bindCookieHook
+}
+
+// SetBindCookieHook sets the given hook as the
+// default cookie binding hook.
+func SetBindCookieHook(bh BindHook) {
+	if bh != nil {
+		bindCookieHook = bh
+	}
+}
+
 // GetRenderHook returns the current render hook.
 func GetRenderHook() RenderHook {
 	return renderHook
@@ -214,10 +322,15 @@ type BindError struct {
 	message       string
 	typ           reflect.Type
 	field         string
+	path          string
+	fieldErrs     []*FieldError
 }
 
 // Error implements the builtin error interface for BindError.
 func (be BindError) Error() string {
+	if be.path != "" {
+		return fmt.Sprintf("binding error at '%s': %s", be.path, be.message)
+	}
 	if be.field != "" && be.typ != nil {
 		return fmt.Sprintf(
 			"binding error on field '%s' of type '%s': %s",
@@ -238,104 +351,259 @@ func (be BindError) ValidationErrors() validator.ValidationErrors {
 	return nil
 }
 
-// An extractorFunc extracts data from a Fiber context according to
-// parameters specified in a field tag.
-type extractor func(*fiber.Ctx, string) (string, []string, error)
+// FieldErrors returns the field-level errors of the
+// binding error, one per failed validation, or a single
+// one if the binding of a field failed. The fields are
+// designated by their wire name, and their location is
+// read from the tags of the input type.
+func (be BindError) FieldErrors() []*FieldError {
+	if be.fieldErrs != nil {
+		return be.fieldErrs
+	}
+	if verrs := be.ValidationErrors(); verrs != nil {
+		errs := make([]*FieldError, 0, len(verrs))
+		for _, fe := range verrs {
+			name, location := fe.Field(), ""
+			if be.typ != nil {
+				// Skip the name of the root type.
+				ns := strings.SplitN(fe.StructNamespace(), ".", 2)
+				name, location = wireField(be.typ, strings.Split(ns[len(ns)-1], "."))
+			}
+			errs = append(errs, &FieldError{
+				Field:    name,
+				Location: location,
+				Tag:      fe.Tag(),
+				Param:    fe.Param(),
+				Message:  validationMessage(fe),
+			})
+		}
+		return errs
+	}
+	if be.path != "" {
+		return []*FieldError{{
+			Field:    be.path,
+			Location: "body",
+			Message:  be.message,
+		}}
+	}
+	if be.field != "" {
+		name, location := be.field, ""
+		if be.typ != nil {
+			name, location = wireField(be.typ, []string{be.field})
+		}
+		return []*FieldError{{
+			Field:    name,
+			Location: location,
+			Message:  be.message,
+		}}
+	}
+	return nil
+}
+
+// wireField returns the wire name and the location of the
+// field of type t designated by the path of Go field names,
+// as found in the namespace of a validation error.
+// Nested fields of the body are joined with a dot.
+func wireField(t reflect.Type, path []string) (string, string) {
+	var (
+		names    []string
+		location = "body"
+		top      = true
+	)
+	for _, p := range path {
+		// Split the index or key of a collection
+		// element, and keep it as is.
+		var idx string
+		if b := strings.IndexByte(p, '['); b != -1 {
+			p, idx = p[:b], p[b:]
+		}
+		for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice ||
+			t.Kind() == reflect.Array || t.Kind() == reflect.Map) {
+			t = t.Elem()
+		}
+		var sf reflect.StructField
+		ok := t != nil && t.Kind() == reflect.Struct
+		if ok {
+			sf, ok = t.FieldByName(p)
+		}
+		if !ok {
+			names = append(names, p+idx)
+			t = nil
+			continue
+		}
+		t = sf.Type
+
+		// Embedded fields are flattened by the
+		// binding and the JSON encoding.
+		if sf.Anonymous && idx == "" {
+			if _, ok := sf.Tag.Lookup("json"); !ok {
+				continue
+			}
+		}
+		name := jsonFieldName(sf)
+		if n, ok := formFieldName(sf); ok {
+			name = n
+		}
+		if top {
+			for _, tag := range []string{QueryTag, PathTag, HeaderTag, CookieTag} {
+				if v, ok := sf.Tag.Lookup(tag); ok {
+					if n, err := ParseTagKey(v); err == nil {
+						name, location = n, tag
+					}
+				}
+			}
+			top = false
+		}
+		names = append(names, name+idx)
+	}
+	return strings.Join(names, "."), location
+}
+
+// jsonFieldName returns the name of the struct
+// field in its JSON encoding.
+func jsonFieldName(sf reflect.StructField) string {
+	name := strings.Split(sf.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+// validationMessage returns a human-readable message
+// for the validation error of a field.
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return fmt.Sprintf("must be at least %s", fe.Param())
+	case "max", "lte":
+		return fmt.Sprintf("must be at most %s", fe.Param())
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", fe.Param())
+	case "lt":
+		return fmt.Sprintf("must be less than %s", fe.Param())
+	case "len":
+		return fmt.Sprintf("must have a length of %s", fe.Param())
+	case "eq":
+		return fmt.Sprintf("must be equal to %s", fe.Param())
+	case "ne":
+		return fmt.Sprintf("must not be equal to %s", fe.Param())
+	case "oneof":
+		return fmt.Sprintf("must be one of [%s]", fe.Param())
+	case "email", "url", "uri", "uuid", "ip", "ipv4", "ipv6", "hostname":
+		return fmt.Sprintf("must be a valid %s", fe.Tag())
+	}
+	return fmt.Sprintf("failed on the '%s' validation", fe.Tag())
+}
+
+// An extractor extracts the values of a parameter from a Fiber
+// context according to the binding plan of a struct field.
+type extractor func(*fiber.Ctx, *paramPlan) ([]string, error)
 
 // extractQuery is an extractor tgat operated on the query
 // parameters of a request.
-func extractQuery(c *fiber.Ctx, tag string) (string, []string, error) {
-	name, required, defaultVal, err := parseTagKey(tag)
-	if err != nil {
-		return "", nil, err
-	}
+func extractQuery(c *fiber.Ctx, pp *paramPlan) ([]string, error) {
 	var params []string
 
-	query := []string{c.Query(name)}
+	// Read all the values of a repeated
+	// parameter, e.g. ?id=1&id=2.
+	values := c.Context().QueryArgs().PeekMulti(pp.name)
 
-	if b, ok := c.Locals(ExplodeTag).(bool); ok && b {
+	if pp.explode {
 		// Delete empty elements so default and required arguments
 		// will play nice together. Append to a new collection to
 		// preserve order without too much copying.
-		params = make([]string, 0, len(query))
-		for i := range query {
-			if query[i] != "" {
-				params = append(params, query[i])
+		params = make([]string, 0, len(values))
+		for _, v := range values {
+			if len(v) != 0 {
+				params = append(params, string(v))
 			}
 		}
 	} else {
 		splitFn := func(c rune) bool {
 			return c == ','
 		}
-		if len(query) > 1 {
-			return name, nil, errors.New("repeating values not supported: use comma-separated list")
-		} else if len(query) == 1 {
-			params = strings.FieldsFunc(query[0], splitFn)
+		if len(values) > 1 {
+			return nil, errors.New("repeating values not supported: use comma-separated list")
+		} else if len(values) == 1 {
+			params = strings.FieldsFunc(string(values[0]), splitFn)
 		}
 	}
 
 	// XXX: deprecated, use of "default" tag is preferred
-	if len(params) == 0 && defaultVal != "" {
-		return name, []string{defaultVal}, nil
+	if len(params) == 0 && pp.tagDefault != "" {
+		return []string{pp.tagDefault}, nil
 	}
 	// XXX: deprecated, use of "validate" tag is preferred
-	if len(params) == 0 && required {
-		return "", nil, fmt.Errorf("missing query parameter: %s", name)
+	if len(params) == 0 && pp.required {
+		return nil, fmt.Errorf("missing query parameter: %s", pp.name)
 	}
-	return name, params, nil
+	return params, nil
 }
 
 // extractPath is an extractor that operates on the path
 // parameters of a request.
-func extractPath(c *fiber.Ctx, tag string) (string, []string, error) {
-	name, required, defaultVal, err := parseTagKey(tag)
-	if err != nil {
-		return "", nil, err
-	}
-	p := c.Params(name)
+func extractPath(c *fiber.Ctx, pp *paramPlan) ([]string, error) {
+	p := c.Params(pp.name)
 
 	// XXX: deprecated, use of "default" tag is preferred
-	if p == "" && defaultVal != "" {
-		return name, []string{defaultVal}, nil
+	if p == "" && pp.tagDefault != "" {
+		return []string{pp.tagDefault}, nil
 	}
 	// XXX: deprecated, use of "validate" tag is preferred
-	if p == "" && required {
-		return "", nil, fmt.Errorf("missing path parameter: %s", name)
+	if p == "" && pp.required {
+		return nil, fmt.Errorf("missing path parameter: %s", pp.name)
 	}
 
-	return name, []string{p}, nil
+	return []string{p}, nil
 }
 
 // extractHeader is an extractor that operates on the headers
 // of a request.
-func extractHeader(c *fiber.Ctx, tag string) (string, []string, error) {
-	name, required, defaultVal, err := parseTagKey(tag)
-	if err != nil {
-		return "", nil, err
+func extractHeader(c *fiber.Ctx, pp *paramPlan) ([]string, error) {
+	header := c.Get(pp.name)
+
+	// XXX: deprecated, use of "default" tag is preferred
+	if header == "" && pp.tagDefault != "" {
+		return []string{pp.tagDefault}, nil
+	}
+	// XXX: deprecated, use of "validate" tag is preferred
+	if pp.required && header == "" {
+		return nil, fmt.Errorf("missing header parameter: %s", pp.name)
 	}
-	header := c.Get(name)
+	return []string{header}, nil
+}
+
+// extractCookie is an extractor that operates on the cookies
+// of a request.
+func extractCookie(c *fiber.Ctx, pp *paramPlan) ([]string, error) {
+	cookie := c.Cookies(pp.name)
 
 	// XXX: deprecated, use of "default" tag is preferred
-	if header == "" && defaultVal != "" {
-		return name, []string{defaultVal}, nil
+	if cookie == "" && pp.tagDefault != "" {
+		return []string{pp.tagDefault}, nil
 	}
 	// XXX: deprecated, use of "validate" tag is preferred
-	if required && header == "" {
-		return "", nil, fmt.Errorf("missing header parameter: %s", name)
+	if pp.required && cookie == "" {
+		return nil, fmt.Errorf("missing cookie parameter: %s", pp.name)
+	}
+	if cookie == "" {
+		return nil, nil
 	}
-	return name, []string{header}, nil
+	return []string{cookie}, nil
 }
 
 // Public signature does not expose "required" and "default" because
 // they are deprecated in favor of the "validate" and "default" tags
-func parseTagKey(tag string) (string, bool, string, error) {
+func parseTagKey(tag string) (string, bool, string, string, error) {
 	parts := strings.Split(tag, ",")
 	if len(parts) == 0 {
-		return "", false, "", fmt.Errorf("empty tag")
+		return "", false, "", "", fmt.Errorf("empty tag")
 	}
 	name, options := parts[0], parts[1:]
 
-	var defaultVal string
+	var defaultVal, style string
 
 	// XXX: deprecated, required + default are kept here for backwards compatibility
 	// use of "default" and "validate" tags is preferred
@@ -348,20 +616,35 @@ func parseTagKey(tag string) (string, bool, string, error) {
 			required = true
 		} else if strings.HasPrefix(o, fmt.Sprintf("%s=", DefaultTag)) {
 			defaultVal = strings.TrimPrefix(o, fmt.Sprintf("%s=", DefaultTag))
+		} else if strings.HasPrefix(o, fmt.Sprintf("%s=", StyleTag)) {
+			style = strings.TrimPrefix(o, fmt.Sprintf("%s=", StyleTag))
+			if style != FormStyle && style != DeepObjectStyle {
+				return "", false, "", "", fmt.Errorf("malformed tag for param '%s': unsupported style '%s'", name, style)
+			}
 		} else {
-			return "", false, "", fmt.Errorf("malformed tag for param '%s': unknown option '%s'", name, o)
+			return "", false, "", "", fmt.Errorf("malformed tag for param '%s': unknown option '%s'", name, o)
 		}
 	}
-	return name, required, defaultVal, nil
+	return name, required, defaultVal, style, nil
 }
 
 // ParseTagKey parses the given struct tag key and return the
 // name of the field
 func ParseTagKey(tag string) (string, error) {
-	s, _, _, err := parseTagKey(tag)
+	s, _, _, _, err := parseTagKey(tag)
 	return s, err
 }
 
+// paramStyle returns the style of the parameter bound from
+// the struct field sf: the style option of its location
+// tag if set, or else the value of its style tag.
+func paramStyle(sf reflect.StructField, option string) string {
+	if option != "" {
+		return option
+	}
+	return sf.Tag.Get(StyleTag)
+}
+
 // bindStringValue converts and bind the value s
 // to the the reflected value v.
 func bindStringValue(s string, v reflect.Value) error {
@@ -425,3 +708,133 @@ func bindStringValue(s string, v reflect.Value) error {
 	return nil
 }
 
+
+// bindDeepObject binds the values of the query parameter
+// with the deepObject style with the given name to the
+// reflected value v, which is a struct, a map with string
+// keys, or a pointer to one of these.
+func bindDeepObject(c *fiber.Ctx, v reflect.Value, name string) error {
+	var err error
+	prefix := name + "["
+
+	c.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		k := string(key)
+		if err != nil || !strings.HasPrefix(k, prefix) {
+			return
+		}
+		path, ok := parseDeepObjectKey(k[len(name):])
+		if !ok {
+			err = fmt.Errorf("malformed deepObject key: %s", k)
+			return
+		}
+		if e := setDeepValue(v, path, string(value)); e != nil {
+			err = fmt.Errorf("%s: %s", k, e)
+		}
+	})
+	return err
+}
+
+// parseDeepObjectKey splits the bracketed segments
+// of a deepObject key, such as [owner][id].
+func parseDeepObjectKey(s string) ([]string, bool) {
+	var path []string
+	for len(s) != 0 {
+		end := strings.IndexByte(s, ']')
+		if s[0] != '[' || end < 2 {
+			return nil, false
+		}
+		path = append(path, s[1:end])
+		s = s[end+1:]
+	}
+	return path, len(path) != 0
+}
+
+// setDeepValue sets the string value s to the element of
+// the reflected value v designated by the path of keys.
+// Struct fields are designated by their JSON name.
+func setDeepValue(v reflect.Value, path []string, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if len(path) == 0 {
+		if v.Kind() == reflect.Slice {
+			e := reflect.New(v.Type().Elem()).Elem()
+			if err := bindStringValue(s, e); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, e))
+			return nil
+		}
+		return bindStringValue(s, v)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		f, ok := deepObjectField(v, path[0])
+		if !ok {
+			// Unknown keys are ignored, as the
+			// unknown fields of a JSON body.
+			return nil
+		}
+		return setDeepValue(f, path[1:], s)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type: %v", v.Type().Key())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		key := reflect.ValueOf(path[0]).Convert(v.Type().Key())
+
+		// Map elements are not addressable, set
+		// a copy of the element back to the map.
+		e := reflect.New(v.Type().Elem()).Elem()
+		if cur := v.MapIndex(key); cur.IsValid() {
+			e.Set(cur)
+		}
+		if err := setDeepValue(e, path[1:], s); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, e)
+		return nil
+	}
+	return fmt.Errorf("unexpected key %s for type %v", path[0], v.Type())
+}
+
+// deepObjectField returns the field of the struct value v
+// with the given JSON name. Embedded structs are flattened.
+func deepObjectField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		f := v.Field(i)
+		if sf.Anonymous {
+			if _, ok := sf.Tag.Lookup("json"); !ok {
+				if f.Kind() == reflect.Ptr {
+					if f.IsNil() {
+						if !f.CanSet() {
+							continue
+						}
+						f.Set(reflect.New(f.Type().Elem()))
+					}
+					f = f.Elem()
+				}
+				if f.Kind() == reflect.Struct {
+					if ef, ok := deepObjectField(f, name); ok {
+						return ef, true
+					}
+				}
+				continue
+			}
+		}
+		if jsonFieldName(sf) == name && sf.Tag.Get("json") != "-" {
+			return f, true
+		}
+	}
+	return reflect.Value{}, false
+}
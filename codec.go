@@ -0,0 +1,404 @@
+package optizz
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/ugorji/go/codec"
+)
+
+// Media types of the builtin codecs.
+const (
+	JSONMediaType        = "application/json"
+	XMLMediaType         = "application/xml"
+	MessagePackMediaType = "application/msgpack"
+	CBORMediaType        = "application/cbor"
+)
+
+// Codec represents the encoding used to decode the request
+// bodies and encode the response payloads of a media type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// codecRegistry holds the registered
+// codecs by media type.
+type codecRegistry struct {
+	mu     sync.RWMutex
+	codecs map[string]Codec
+}
+
+var codecs = newCodecRegistry()
+
+func newCodecRegistry() *codecRegistry {
+	r := &codecRegistry{codecs: make(map[string]Codec)}
+	r.register(JSONMediaType, jsonCodec{})
+	r.register(XMLMediaType, xmlCodec{})
+	r.register(MessagePackMediaType, newMsgpackCodec())
+	r.register(CBORMediaType, newCBORCodec())
+	return r
+}
+
+func (r *codecRegistry) register(mt string, c Codec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.codecs[mt] = c
+}
+
+// lookup returns the codec of the media type mt. The
+// media types with a +json or +xml structured syntax
+// suffix use the JSON or XML codec if they have none.
+func (r *codecRegistry) lookup(mt string) (Codec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c, ok := r.codecs[mt]; ok {
+		return c, true
+	}
+	switch {
+	case strings.HasSuffix(mt, "+json"):
+		c, ok := r.codecs[JSONMediaType]
+		return c, ok
+	case strings.HasSuffix(mt, "+xml"):
+		c, ok := r.codecs[XMLMediaType]
+		return c, ok
+	}
+	return nil, false
+}
+
+// RegisterCodec registers the codec used for the given
+// media type, replacing the existing one, if any.
+func RegisterCodec(mediaType string, c Codec) {
+	if mediaType == "" || c == nil {
+		return
+	}
+	codecs.register(strings.ToLower(mediaType), c)
+}
+
+// GetCodec returns the codec registered for
+// the given media type.
+func GetCodec(mediaType string) (Codec, bool) {
+	return codecs.lookup(normalizeMediaType(mediaType))
+}
+
+// normalizeMediaType returns the media type of a
+// Content-Type header value, without its parameters.
+func normalizeMediaType(ct string) string {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		mt = strings.TrimSpace(strings.Split(ct, ";")[0])
+	}
+	return strings.ToLower(mt)
+}
+
+// negotiateResponse negotiates the media type of the response
+// with the Accept header of the request, among the media types
+// produced by the operation, and stores it in the context. It
+// returns the preferred media type and false if none is
+// acceptable. The operations that declare none respond
+// with JSON, whatever the Accept header.
+func negotiateResponse(c *fiber.Ctx) (string, bool) {
+	offers, _ := c.Locals(ctxProduces).([]string)
+	if len(offers) == 0 {
+		c.Locals(ctxMediaType, JSONMediaType)
+		return JSONMediaType, true
+	}
+	mt, ok := negotiate(c.Get(fiber.HeaderAccept), offers)
+	c.Locals(ctxMediaType, mt)
+	return mt, ok
+}
+
+// ResponseMediaType returns the media type of the response
+// negotiated with the Accept header of the request, which
+// the render hooks encode the payload to. The media type
+// is negotiated before the handler is called, and on
+// first use for the requests rejected before that.
+func ResponseMediaType(c *fiber.Ctx) string {
+	if mt, ok := c.Locals(ctxMediaType).(string); ok {
+		return mt
+	}
+	mt, _ := negotiateResponse(c)
+	return mt
+}
+
+// negotiate returns the offered media type with the highest
+// quality in the Accept header, the first one of them on a
+// tie, or the first offer and false if none is acceptable.
+func negotiate(accept string, offers []string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+	best, bestQ := offers[0], 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// acceptQuality returns the quality of the media type mt
+// in the Accept header, given by its most specific media
+// range, or 0 if none matches.
+func acceptQuality(accept, mt string) float64 {
+	q, specificity := 0.0, -1
+	for _, r := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(r))
+		if err != nil {
+			continue
+		}
+		var s int
+		switch {
+		case rng == mt:
+			s = 2
+		case strings.HasSuffix(rng, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(rng, "*")):
+			s = 1
+		case rng == "*/*":
+			s = 0
+		default:
+			continue
+		}
+		rq := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				rq = f
+			}
+		}
+		if s > specificity || s == specificity && rq > q {
+			q, specificity = rq, s
+		}
+	}
+	return q
+}
+
+// consumesMediaType returns whether the media type of the
+// request body is consumed by the operation.
+func consumesMediaType(c *fiber.Ctx, mt string) bool {
+	mts, _ := c.Locals(ctxConsumes).([]string)
+	if len(mts) == 0 {
+		return true
+	}
+	for _, m := range mts {
+		if strings.EqualFold(m, mt) {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// isJSONMediaType returns whether mt is the JSON media
+// type or has the +json structured syntax suffix.
+func isJSONMediaType(mt string) bool {
+	return mt == JSONMediaType || strings.HasSuffix(mt, "+json")
+}
+
+// decodeStrictJSON decodes the JSON data to v. Unlike the
+// JSON codec, it returns a BindError if the data has fields
+// that are unknown to the type of v, or trailing data after
+// the top-level value.
+func decodeStrictJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return fmt.Errorf("error parsing request body: %s", err.Error())
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return BindError{message: "unexpected data after the top-level value of the body"}
+	}
+	if path, ok := unknownField(raw, reflect.TypeOf(v), ""); ok {
+		return BindError{path: path, message: "unknown field"}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		var ute *json.UnmarshalTypeError
+		if errors.As(err, &ute) && ute.Field != "" {
+			return BindError{path: indexPath(ute.Field), message: fmt.Sprintf(
+				"cannot decode a JSON %s into a value of type %s", ute.Value, ute.Type),
+			}
+		}
+		return fmt.Errorf("error parsing request body: %s", err.Error())
+	}
+	return nil
+}
+
+// indexPath formats the indexes of the dotted path
+// of a JSON decoding error with brackets, such as
+// items[0].name for items.0.name.
+func indexPath(path string) string {
+	var b strings.Builder
+	for i, p := range strings.Split(path, ".") {
+		if _, err := strconv.Atoi(p); err == nil && i != 0 {
+			b.WriteString("[" + p + "]")
+			continue
+		}
+		if i != 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(p)
+	}
+	return b.String()
+}
+
+// unknownField returns the path of the first field of the
+// decoded JSON value raw that is unknown to the type t.
+// Values decoded by a json.Unmarshaler are not inspected.
+func unknownField(raw interface{}, t reflect.Type, path string) (string, bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return "", false
+	}
+	switch val := raw.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fields := bodyFields(t)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			var et reflect.Type
+			switch t.Kind() {
+			case reflect.Struct:
+				sf, ok := fields[strings.ToLower(k)]
+				if !ok {
+					return p, true
+				}
+				et = sf.Type
+			case reflect.Map:
+				et = t.Elem()
+			default:
+				return "", false
+			}
+			if p, ok := unknownField(val[k], et, p); ok {
+				return p, true
+			}
+		}
+	case []interface{}:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return "", false
+		}
+		for i, e := range val {
+			if p, ok := unknownField(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); ok {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// bodyFields returns the fields of the struct type t
+// encoded in the body, by lowercased JSON name, with
+// the fields of the embedded structs flattened.
+func bodyFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	var embedded []reflect.Type
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && strings.Split(tag, ",")[0] == "" {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if sf.PkgPath != "" || !isBodyField(sf) {
+			continue
+		}
+		fields[strings.ToLower(jsonFieldName(sf))] = sf
+	}
+	for _, et := range embedded {
+		for name, sf := range bodyFields(et) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = sf
+			}
+		}
+	}
+	return fields
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// handleCodec is a codec based on a codec.Handle.
+// The struct fields are named after their codec
+// tag, or their json tag if they have none.
+type handleCodec struct {
+	h codec.Handle
+}
+
+func newMsgpackCodec() handleCodec {
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.RawToString = true
+	h.WriteExt = true
+	return handleCodec{h: h}
+}
+
+func newCBORCodec() handleCodec {
+	h := &codec.CborHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return handleCodec{h: h}
+}
+
+func (hc handleCodec) Marshal(v interface{}) ([]byte, error) {
+	var b []byte
+	if err := codec.NewEncoderBytes(&b, hc.h).Encode(v); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (hc handleCodec) Unmarshal(data []byte, v interface{}) error {
+	return codec.NewDecoder(bytes.NewReader(data), hc.h).Decode(v)
+}
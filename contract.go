@@ -0,0 +1,892 @@
+package optizz
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+	"gopkg.in/yaml.v2"
+)
+
+// contract holds the operations of a specification
+// loaded with FromSpec, implemented by the handlers
+// registered with Implement.
+type contract struct {
+	mu  sync.Mutex
+	ops map[string]*contractOperation
+}
+
+// contractOperation is an operation of the contract,
+// designated by its ID, or by its method and path
+// if it has none.
+type contractOperation struct {
+	path        string
+	method      string
+	op          *openapi.Operation
+	item        *openapi.PathItem
+	security    []SecurityRequirement
+	implemented bool
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// FromSpec creates a new Optizz wrapper from an existing Fiber
+// app and the OpenAPI 3.0 specification spec, in JSON or YAML.
+// The routes of the operations of the specification are
+// registered with Implement, and the served specification
+// is the given one, plus the routes added with Handle.
+//
+// The generator cannot represent every construct of OpenAPI:
+// for the checks of Implement and the validation of the
+// requests, the allOf compositions are merged into a single
+// schema, the oneOf and anyOf compositions of several
+// schemas accept any value, and only the component schemas
+// keep an additionalProperties of false. The numeric bounds,
+// including those of 0 or that are not integers, are enforced
+// as written. The paths and the components of the
+// specification are still served as written.
+func FromSpec(app *fiber.App, spec []byte, opts ...Option) (*Optizz, error) {
+	doc, err := decodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	f := NewFromApp(app, opts...)
+
+	// Record the component schemas that do not allow
+	// additional properties before they are normalized.
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for name, s := range schemas {
+		if schema, ok := s.(map[string]interface{}); ok && schema["additionalProperties"] == false {
+			f.specExt.closedSchemas[name] = false
+		}
+	}
+	var schemes map[string]*SecurityScheme
+	if err := decodeSpecValue(components["securitySchemes"], &schemes); err != nil {
+		return nil, err
+	}
+	for name, scheme := range schemes {
+		f.SecurityScheme(name, scheme)
+	}
+	var security []SecurityRequirement
+	if err := decodeSpecValue(doc["security"], &security); err != nil {
+		return nil, err
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+
+	// Keep the paths and the components as written,
+	// the document is normalized in place.
+	f.specExt.contractPaths = copyValue(paths).(map[string]interface{})
+	f.specExt.contractComponents = copyValue(components).(map[string]interface{})
+
+	normalizeSpec(doc, schemas, 0)
+	resolveRequestBodies(paths, components)
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var api openapi.OpenAPI
+	if err := json.Unmarshal(b, &api); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI specification: %s", err)
+	}
+	if !strings.HasPrefix(api.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q", api.OpenAPI)
+	}
+	if api.Info != nil {
+		f.gen.SetInfo(api.Info)
+	}
+	if api.Servers != nil {
+		f.gen.SetServers(api.Servers)
+	}
+	for _, t := range api.Tags {
+		f.gen.AddTag(t.Name, t.Description)
+	}
+	gen := f.gen.API()
+	if c := api.Components; c != nil {
+		copyComponents(c.Schemas, &gen.Components.Schemas)
+		copyComponents(c.Responses, &gen.Components.Responses)
+		copyComponents(c.Parameters, &gen.Components.Parameters)
+		copyComponents(c.Examples, &gen.Components.Examples)
+		copyComponents(c.Headers, &gen.Components.Headers)
+	}
+	f.contract = &contract{ops: make(map[string]*contractOperation)}
+
+	for path, pi := range api.Paths {
+		if pi == nil {
+			continue
+		}
+		if err := checkPathTemplate(path); err != nil {
+			return nil, err
+		}
+		gen.Paths[path] = pi
+
+		for _, method := range pathMethods {
+			op := pathOperation(pi, method)
+			if op == nil {
+				continue
+			}
+			key := op.ID
+			if key == "" {
+				key = method + " " + path
+			}
+			if _, ok := f.contract.ops[key]; ok {
+				return nil, fmt.Errorf("duplicate operation %s", key)
+			}
+			// The operations without requirements
+			// inherit those of the specification.
+			item, _ := paths[path].(map[string]interface{})
+			raw, _ := item[strings.ToLower(method)].(map[string]interface{})
+			var opSecurity []SecurityRequirement
+			if err := decodeSpecValue(raw["security"], &opSecurity); err != nil {
+				return nil, err
+			}
+			if _, ok := raw["security"]; !ok {
+				opSecurity = security
+			}
+			if opSecurity != nil {
+				f.specExt.security[operationKey{path: path, method: strings.ToLower(method)}] = opSecurity
+			}
+			f.contract.ops[key] = &contractOperation{
+				path:     path,
+				method:   method,
+				op:       op,
+				item:     pi,
+				security: opSecurity,
+			}
+		}
+	}
+	f.spec.invalidate()
+
+	return f, nil
+}
+
+// decodeSpecValue decodes the generic value v
+// of the specification, if any, to dst.
+func decodeSpecValue(v, dst interface{}) error {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, dst); err != nil {
+		return fmt.Errorf("invalid OpenAPI specification: %s", err)
+	}
+	return nil
+}
+
+// decodeSpec decodes the JSON or YAML
+// specification spec to generic values.
+func decodeSpec(spec []byte) (map[string]interface{}, error) {
+	var doc map[string]interface{}
+
+	if b := bytes.TrimSpace(spec); len(b) != 0 && b[0] == '{' {
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("invalid OpenAPI specification: %s", err)
+		}
+		return doc, nil
+	}
+	var v interface{}
+	if err := yaml.Unmarshal(spec, &v); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI specification: %s", err)
+	}
+	doc, ok := stringKeys(v).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid OpenAPI specification: not an object")
+	}
+	return doc, nil
+}
+
+// stringKeys converts the maps decoded from YAML, which
+// have keys of any type, to maps with string keys.
+func stringKeys(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range val {
+			val[i] = stringKeys(e)
+		}
+	}
+	return v
+}
+
+// normalizeSpec rewrites in place the constructs of the generic
+// specification v that the types of the generator cannot
+// represent. The references of the allOf compositions are
+// resolved against the component schemas.
+func normalizeSpec(v interface{}, schemas map[string]interface{}, depth int) {
+	if depth > 64 {
+		return
+	}
+	switch val := v.(type) {
+	case []interface{}:
+		for _, e := range val {
+			normalizeSpec(e, schemas, depth+1)
+		}
+		return
+	case map[string]interface{}:
+		if _, ok := val["additionalProperties"].(bool); ok {
+			delete(val, "additionalProperties")
+		}
+		for _, k := range []string{"minimum", "maximum", "multipleOf"} {
+			if n, ok := val[k].(float64); ok && n != math.Trunc(n) {
+				delete(val, k)
+			}
+		}
+		// The members may be compositions too.
+		for i := 0; i < 32; i++ {
+			all, ok := val["allOf"].([]interface{})
+			if !ok {
+				break
+			}
+			delete(val, "allOf")
+			for _, s := range all {
+				mergeSchema(val, resolveSchema(s, schemas))
+			}
+		}
+		for _, k := range []string{"oneOf", "anyOf"} {
+			if s, ok := val[k].([]interface{}); ok {
+				if len(s) == 1 {
+					val[k] = s[0]
+				} else {
+					delete(val, k)
+				}
+			}
+		}
+		for _, e := range val {
+			normalizeSpec(e, schemas, depth+1)
+		}
+	}
+}
+
+// resolveRequestBodies replaces in place the references of the
+// request bodies of the operations of the generic paths with
+// the request bodies of the components they refer to, since
+// the operations of the generator cannot hold references.
+func resolveRequestBodies(paths, components map[string]interface{}) {
+	bodies, _ := components["requestBodies"].(map[string]interface{})
+	for _, pi := range paths {
+		item, _ := pi.(map[string]interface{})
+		for _, op := range item {
+			op, ok := op.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			rb := op["requestBody"]
+			for i := 0; i < 32; i++ {
+				m, ok := rb.(map[string]interface{})
+				if !ok {
+					break
+				}
+				ref, ok := m["$ref"].(string)
+				if !ok {
+					if i != 0 {
+						op["requestBody"] = copyValue(m)
+					}
+					break
+				}
+				rb = bodies[strings.TrimPrefix(ref, "#/components/requestBodies/")]
+			}
+		}
+	}
+}
+
+// resolveSchema returns the generic schema s, or the
+// component schema it refers to.
+func resolveSchema(s interface{}, schemas map[string]interface{}) map[string]interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		s = schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+	}
+	return nil
+}
+
+// writtenComponent returns the generic value v of the
+// specification loaded with FromSpec, or the component
+// of the kind it refers to in the written components.
+func writtenComponent(components map[string]interface{}, kind string, v interface{}) map[string]interface{} {
+	byName, _ := components[kind].(map[string]interface{})
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return m
+		}
+		v = byName[strings.TrimPrefix(ref, "#/components/"+kind+"/")]
+	}
+	return nil
+}
+
+// writtenOperation returns the operation of the specification
+// loaded with FromSpec at the path template for the method,
+// and its path item, as written, or nil if it has none.
+func (se *specExtensions) writtenOperation(path, method string) (op, item map[string]interface{}) {
+	item, _ = se.contractPaths[path].(map[string]interface{})
+	op, _ = item[strings.ToLower(method)].(map[string]interface{})
+	return op, item
+}
+
+// writtenParameter returns the written parameter p
+// of the written operation op of the path item.
+func (sv *schemaValidator) writtenParameter(op, item map[string]interface{}, p *openapi.Parameter) map[string]interface{} {
+	for _, list := range []interface{}{op["parameters"], item["parameters"]} {
+		params, _ := list.([]interface{})
+		for _, v := range params {
+			wp := writtenComponent(sv.written, "parameters", v)
+			if wp != nil && wp["name"] == p.Name && wp["in"] == p.In {
+				return wp
+			}
+		}
+	}
+	return nil
+}
+
+// writtenBodySchema returns the written schema of the request
+// body of media type mt of the written operation op.
+func (sv *schemaValidator) writtenBodySchema(op map[string]interface{}, mt string) interface{} {
+	rb := writtenComponent(sv.written, "requestBodies", op["requestBody"])
+	return writtenContentSchema(rb, mt)
+}
+
+// writtenResponseSchema returns the written schema of the response
+// of media type mt for the status code of the written operation op.
+func (sv *schemaValidator) writtenResponseSchema(op map[string]interface{}, code, mt string) interface{} {
+	responses, _ := op["responses"].(map[string]interface{})
+	r := writtenComponent(sv.written, "responses", responses[code])
+	return writtenContentSchema(r, mt)
+}
+
+// writtenContentSchema returns the schema of the content of
+// media type mt of a written request body or response.
+func writtenContentSchema(v map[string]interface{}, mt string) interface{} {
+	content, _ := v["content"].(map[string]interface{})
+	media, _ := content[mt].(map[string]interface{})
+	return media["schema"]
+}
+
+// mergeSchema merges the generic schema src, a member
+// of an allOf composition, into the schema dst.
+func mergeSchema(dst, src map[string]interface{}) {
+	for k, v := range src {
+		switch k {
+		case "properties":
+			props, _ := dst[k].(map[string]interface{})
+			if props == nil {
+				props = make(map[string]interface{})
+				dst[k] = props
+			}
+			if m, ok := v.(map[string]interface{}); ok {
+				for name, p := range m {
+					props[name] = p
+				}
+			}
+		case "required":
+			req, _ := dst[k].([]interface{})
+			if r, ok := v.([]interface{}); ok {
+				dst[k] = append(append([]interface{}(nil), req...), r...)
+			}
+		default:
+			if _, ok := dst[k]; !ok {
+				dst[k] = v
+			}
+		}
+	}
+}
+
+// copyComponents adds the components of src
+// to the components dst, allocated if nil.
+func copyComponents[T any](src map[string]T, dst *map[string]T) {
+	if len(src) == 0 {
+		return
+	}
+	if *dst == nil {
+		*dst = make(map[string]T, len(src))
+	}
+	for name, c := range src {
+		(*dst)[name] = c
+	}
+}
+
+// Implement registers the handler of the operation of the
+// specification loaded with FromSpec, designated by its ID, on
+// the path and method of the operation. The operations without
+// ID are designated by their method and path, such as
+// "GET /pets/{id}".
+// It returns an error if the input or output types of the
+// handler do not match the parameters, the request body or the
+// response of the operation, before the route is registered.
+// The security requirements and the tags set with the options
+// of the handler apply in place of those of the operation.
+func (f *Optizz) Implement(operationID string, handler *OptizzHandler, middlewares ...fiber.Handler) error {
+	if f.contract == nil {
+		return errors.New("no specification was loaded with FromSpec")
+	}
+	if handler == nil {
+		return fmt.Errorf("nil handler for operation %s", operationID)
+	}
+	f.contract.mu.Lock()
+	defer f.contract.mu.Unlock()
+
+	co, ok := f.contract.ops[operationID]
+	if !ok {
+		return fmt.Errorf("unknown operation %s", operationID)
+	}
+	if co.implemented {
+		return fmt.Errorf("operation %s is already implemented", operationID)
+	}
+	components := f.gen.API().Components
+
+	cc := &contractChecker{components: components, closed: make(map[*openapi.Schema]bool)}
+	for name := range f.specExt.closedSchemas {
+		if sor := components.Schemas[name]; sor != nil && sor.Schema != nil {
+			cc.closed[sor.Schema] = true
+		}
+	}
+	cc.checkInput(co, handler.RouteInfo.InputType())
+	cc.checkOutput(co.op, handler.RouteInfo.GetDefaultStatusCode(), handler.RouteInfo.OutputType())
+	if len(cc.errs) != 0 {
+		return fmt.Errorf("handler %s does not match operation %s: %s",
+			handler.RouteInfo.HandlerName(), operationID, strings.Join(cc.errs, "; "))
+	}
+	// The security requirements and the tags set with
+	// the options of the handler apply in place of
+	// those of the specification.
+	key := operationKey{path: co.path, method: strings.ToLower(co.method)}
+	if ext := handler.ext; ext.security != nil {
+		co.security = ext.security
+		f.specExt.security[key] = co.security
+	}
+	if ext := handler.ext; ext.setTags || len(ext.addTags) != 0 {
+		co.op.Tags = mergeTags(f.gen, co.op.Tags, ext)
+		if item, ok := f.specExt.contractPaths[co.path].(map[string]interface{}); ok {
+			if raw, ok := item[key.method].(map[string]interface{}); ok {
+				raw["tags"] = co.op.Tags
+			}
+		}
+	}
+	// The media types documented by the specification
+	// apply unless the handler sets its own.
+	consumes, produces := contractMediaTypes(co.op, handler.RouteInfo.GetDefaultStatusCode())
+	written, _ := f.specExt.writtenOperation(co.path, co.method)
+	f.opRoutes[key] = &operationRoute{scope: f.RouterGroup.scope, in: handler.RouteInfo.InputType()}
+	serve := f.RouterGroup.scope.handler(handler, &operationSpec{
+		op:                co.op,
+		components:        components,
+		written:           written,
+		writtenComponents: f.specExt.contractComponents,
+	})
+
+	security := func() []SecurityRequirement { return co.security }
+	handlers := []fiber.Handler{f.guard(f.RouterGroup.scope, security)}
+	handlers = append(handlers, middlewares...)
+	handlers = append(handlers, func(c *fiber.Ctx) error {
+		if consumes != nil {
+			c.Locals(ctxConsumes, consumes)
+		}
+		if produces != nil {
+			c.Locals(ctxProduces, produces)
+		}
+		return serve(c)
+	})
+	f.RouterGroup.group.Add(co.method, fiberPath(co.path), handlers...)
+	route := newRoute(handler, co.method, fiberPath(co.path), "", co.op)
+	route.security = security
+	f.registry.add(route, handler)
+	co.implemented = true
+	f.spec.invalidate()
+
+	return nil
+}
+
+// Unimplemented returns the sorted IDs of the operations of the
+// specification loaded with FromSpec that have no handler.
+func (f *Optizz) Unimplemented() []string {
+	if f.contract == nil {
+		return nil
+	}
+	f.contract.mu.Lock()
+	defer f.contract.mu.Unlock()
+
+	var ids []string
+	for id, co := range f.contract.ops {
+		if !co.implemented {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// fiberPath converts the parameters of an OpenAPI
+// path template to the syntax of Fiber, including
+// those that share a segment, such as {name}.{ext}.
+func fiberPath(path string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(path, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(path[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(path[:i])
+		b.WriteByte(':')
+		b.WriteString(path[i+1 : i+j])
+		path = path[i+j+1:]
+	}
+	b.WriteString(path)
+	return b.String()
+}
+
+// checkPathTemplate returns an error if the parameters of
+// the OpenAPI path template cannot be expressed with the
+// syntax of Fiber, where a parameter name ends at the first
+// delimiter, and a parameter must be followed by the end of
+// the path, a slash, a dash or a dot.
+func checkPathTemplate(path string) error {
+	for rest := path; ; {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			if strings.IndexByte(rest, '}') >= 0 {
+				return fmt.Errorf("invalid path template %s: unbalanced braces", path)
+			}
+			return nil
+		}
+		if strings.IndexByte(rest[:i], '}') >= 0 {
+			return fmt.Errorf("invalid path template %s: unbalanced braces", path)
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return fmt.Errorf("invalid path template %s: unbalanced braces", path)
+		}
+		name := rest[i+1 : i+j]
+		if name == "" || strings.ContainsAny(name, "{/-.:?+*") {
+			return fmt.Errorf("invalid path template %s: unsupported parameter name %q", path, name)
+		}
+		rest = rest[i+j+1:]
+		if rest != "" && !strings.ContainsRune("/-.", rune(rest[0])) {
+			return fmt.Errorf("invalid path template %s: parameter %q must be followed by a slash, a dash or a dot", path, name)
+		}
+	}
+}
+
+// contractMediaTypes returns the media types of the request body
+// of the operation, and of its response for the status code.
+func contractMediaTypes(op *openapi.Operation, status int) (consumes, produces []string) {
+	if op.RequestBody != nil {
+		consumes = bodyMediaTypes(op.RequestBody)
+	}
+	if r := contractResponse(op, status); r != nil {
+		for mt := range r.Content {
+			produces = append(produces, mt)
+		}
+		// JSON is preferred when the client accepts
+		// several of the media types.
+		sort.SliceStable(produces, func(i, j int) bool {
+			return produces[i] == JSONMediaType || (produces[j] != JSONMediaType && produces[i] < produces[j])
+		})
+	}
+	return consumes, produces
+}
+
+// contractResponse returns the response of the operation
+// for the status code, the response of its range, such
+// as 2XX, or the default response.
+func contractResponse(op *openapi.Operation, status int) *openapi.Response {
+	code := strconv.Itoa(status)
+	for _, k := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if r, ok := op.Responses[k]; ok && r != nil && r.Response != nil {
+			return r.Response
+		}
+	}
+	return nil
+}
+
+// contractChecker checks the compatibility of the
+// input and output types of a handler with an
+// operation, and collects the mismatches.
+type contractChecker struct {
+	components *openapi.Components
+	// closed are the schemas that do not allow additional
+	// properties, the generator cannot represent them.
+	closed  map[*openapi.Schema]bool
+	errs    []string
+	visited map[contractVisit]bool
+}
+
+type contractVisit struct {
+	t reflect.Type
+	s *openapi.Schema
+}
+
+func (cc *contractChecker) fail(format string, a ...interface{}) {
+	cc.errs = append(cc.errs, fmt.Sprintf(format, a...))
+}
+
+func (cc *contractChecker) resolve(sor *openapi.SchemaOrRef) *openapi.Schema {
+	return (&schemaValidator{components: cc.components}).resolve(sor)
+}
+
+// parameters returns the parameters of the operation,
+// with the parameters of its path item that it
+// does not override.
+func (cc *contractChecker) parameters(co *contractOperation) []*openapi.Parameter {
+	return operationParameters(cc.components, co.item, co.op)
+}
+
+// checkInput checks that the input type t binds the parameters
+// and the request body of the operation, and nothing else.
+func (cc *contractChecker) checkInput(co *contractOperation, t reflect.Type) {
+	params := cc.parameters(co)
+
+	if t == nil {
+		for _, p := range params {
+			cc.fail("%s parameter %q is not bound, the handler has no input", p.In, p.Name)
+		}
+		if co.op.RequestBody != nil && co.op.RequestBody.Required {
+			cc.fail("the request body is not bound, the handler has no input")
+		}
+		return
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	plan := planOf(t)
+	documented := make(map[string]bool)
+
+	for _, p := range params {
+		documented[p.In+":"+p.Name] = true
+
+		var pp *paramPlan
+		for _, e := range plan.params[p.In] {
+			if e.name == p.Name {
+				pp = e
+			}
+		}
+		if pp == nil {
+			cc.fail("%s parameter %q is not bound by a field of type %s", p.In, p.Name, t)
+			continue
+		}
+		sf, _ := pp.owner.FieldByName(pp.field)
+		if p.Required && !pp.required && !isRequired(sf) && p.In != PathTag {
+			cc.fail("field %s binds the required %s parameter %q, but is optional", pp.field, p.In, p.Name)
+		}
+		if pp.deepObject {
+			continue
+		}
+		cc.checkType(p.Schema, sf.Type, fmt.Sprintf("field %s (%s parameter %q)", pp.field, p.In, p.Name), 0)
+	}
+	for _, loc := range paramLocations {
+		for _, pp := range plan.params[loc] {
+			if !documented[loc+":"+pp.name] {
+				cc.fail("field %s is bound from the %s parameter %q, which is not documented", pp.field, loc, pp.name)
+			}
+		}
+	}
+	body := bodyFields(t)
+	rb := co.op.RequestBody
+
+	if rb == nil {
+		if len(body) != 0 {
+			cc.fail("type %s has body fields, but the operation has no request body", t)
+		}
+		return
+	}
+	sor, ok := contentSchema(rb.Content)
+	if !ok {
+		return
+	}
+	cc.checkType(sor, t, "request body", 0)
+}
+
+// checkOutput checks that the output type t of a handler
+// rendered with the status code matches the response
+// of the operation.
+func (cc *contractChecker) checkOutput(op *openapi.Operation, status int, t reflect.Type) {
+	r := contractResponse(op, status)
+	if r == nil {
+		cc.fail("no response is documented for the status code %d", status)
+		return
+	}
+	var sor *openapi.SchemaOrRef
+	for mt, content := range r.Content {
+		if content != nil && content.MediaType != nil && (isJSONMediaType(mt) || sor == nil) {
+			sor = content.Schema
+		}
+	}
+	switch {
+	case t == nil && sor != nil:
+		cc.fail("the response %d has a body, but the handler has no output", status)
+	case t != nil && len(r.Content) == 0:
+		cc.fail("the handler returns %s, but the response %d has no body", t, status)
+	case t != nil && sor != nil:
+		cc.checkType(sor, t, "response body", 0)
+	}
+}
+
+// contentSchema returns the schema of the request body content,
+// preferring JSON, if one of its media types is decoded with a
+// codec that names the fields after their json tag.
+func contentSchema(content map[string]*openapi.MediaType) (*openapi.SchemaOrRef, bool) {
+	var sor *openapi.SchemaOrRef
+	var ok bool
+	for mt, c := range content {
+		if c == nil {
+			continue
+		}
+		if isJSONMediaType(mt) {
+			return c.Schema, true
+		}
+		if mt == MessagePackMediaType || mt == CBORMediaType {
+			sor, ok = c.Schema, true
+		}
+	}
+	return sor, ok
+}
+
+// checkType checks that the values of type t, designated by
+// what in the messages, are compatible with the schema sor.
+func (cc *contractChecker) checkType(sor *openapi.SchemaOrRef, t reflect.Type, what string, depth int) {
+	s := cc.resolve(sor)
+	if s == nil || depth > 32 {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if s.AllOf != nil {
+		cc.checkType(s.AllOf, t, what, depth+1)
+	}
+	v := contractVisit{t, s}
+	if cc.visited[v] {
+		return
+	}
+	if cc.visited == nil {
+		cc.visited = make(map[contractVisit]bool)
+	}
+	cc.visited[v] = true
+
+	// The types with a custom encoding
+	// are assumed to be compatible.
+	pt := reflect.PtrTo(t)
+	if t.Implements(jsonMarshalerType) || pt.Implements(jsonUnmarshalerType) {
+		return
+	}
+	if t.Implements(textMarshalerType) || pt.Implements(textUnmarshalerType) {
+		if s.Type != "" && s.Type != "string" {
+			cc.fail("%s of type %s is encoded as a string, but the schema is of type %s", what, t, s.Type)
+		}
+		return
+	}
+	if t.Kind() == reflect.Interface {
+		return
+	}
+	ok := true
+	switch s.Type {
+	case "string":
+		ok = t.Kind() == reflect.String || (t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8)
+	case "integer":
+		ok = isIntKind(t.Kind())
+	case "number":
+		ok = isIntKind(t.Kind()) || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+	case "boolean":
+		ok = t.Kind() == reflect.Bool
+	case "array":
+		ok = t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+		if ok {
+			cc.checkType(s.Items, t.Elem(), what+" items", depth+1)
+		}
+	case "object", "":
+		switch t.Kind() {
+		case reflect.Struct:
+			cc.checkStruct(s, t, what, depth)
+		case reflect.Map:
+			if s.AdditionalProperties != nil {
+				cc.checkType(s.AdditionalProperties, t.Elem(), what+" values", depth+1)
+			}
+		default:
+			ok = s.Type == ""
+		}
+	}
+	if !ok {
+		cc.fail("%s of type %s does not match the schema of type %s", what, t, s.Type)
+	}
+}
+
+// checkStruct checks the fields of the struct type t against
+// the properties of the object schema s. The required properties
+// must be fields of t, and the fields of t must be properties
+// of s if it is a component schema that does not allow
+// additional properties. The inline schemas are open, as
+// their additionalProperties are not kept by the generator.
+func (cc *contractChecker) checkStruct(s *openapi.Schema, t reflect.Type, what string, depth int) {
+	fields := bodyFields(t)
+
+	props := make(map[string]string, len(s.Properties))
+	for name := range s.Properties {
+		props[strings.ToLower(name)] = name
+	}
+	for _, r := range s.Required {
+		if _, ok := fields[strings.ToLower(r)]; !ok {
+			cc.fail("%s: the required property %q is not a field of type %s", what, r, t)
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sf := fields[name]
+		prop, ok := props[name]
+		if !ok {
+			if cc.closed[s] {
+				cc.fail("%s: field %s of type %s is not documented", what, sf.Name, t)
+			}
+			continue
+		}
+		cc.checkType(s.Properties[prop], sf.Type, fmt.Sprintf("%s: field %s", what, sf.Name), depth+1)
+	}
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package optizz
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wI2L/fizz/openapi"
+)
+
+// HTTPError is an error type that carries the HTTP status
+// code of the response. Handlers can return it to control
+// the status code rendered by the default error hook.
+// It is also the model of the error responses documented
+// with the Errors operation option.
+type HTTPError struct {
+	Status  int         `json:"-"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// Error implements the builtin error interface for HTTPError.
+func (e *HTTPError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("%d %s: %s", e.status(), e.Code, e.Message)
+	}
+	return fmt.Sprintf("%d: %s", e.status(), e.Message)
+}
+
+// WithCode sets the application-specific code
+// of the error and returns it.
+func (e *HTTPError) WithCode(code string) *HTTPError {
+	e.Code = code
+	return e
+}
+
+// WithDetails sets the details of the error
+// and returns it.
+func (e *HTTPError) WithDetails(details interface{}) *HTTPError {
+	e.Details = details
+	return e
+}
+
+// status returns the status code of the error,
+// default to 500 if none is set.
+func (e *HTTPError) status() int {
+	if e.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return e.Status
+}
+
+// NewHTTPError returns a new HTTPError with the given status
+// code and message. If message is empty, the status text
+// of the code is used.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{
+		Status:  status,
+		Message: message,
+	}
+}
+
+// BadRequest returns a new 400 HTTPError.
+func BadRequest(message string) *HTTPError {
+	return NewHTTPError(http.StatusBadRequest, message)
+}
+
+// Unauthorized returns a new 401 HTTPError.
+func Unauthorized(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnauthorized, message)
+}
+
+// Forbidden returns a new 403 HTTPError.
+func Forbidden(message string) *HTTPError {
+	return NewHTTPError(http.StatusForbidden, message)
+}
+
+// NotFound returns a new 404 HTTPError.
+func NotFound(message string) *HTTPError {
+	return NewHTTPError(http.StatusNotFound, message)
+}
+
+// Conflict returns a new 409 HTTPError.
+func Conflict(message string) *HTTPError {
+	return NewHTTPError(http.StatusConflict, message)
+}
+
+// UnprocessableEntity returns a new 422 HTTPError.
+func UnprocessableEntity(message string) *HTTPError {
+	return NewHTTPError(http.StatusUnprocessableEntity, message)
+}
+
+// TooManyRequests returns a new 429 HTTPError.
+func TooManyRequests(message string) *HTTPError {
+	return NewHTTPError(http.StatusTooManyRequests, message)
+}
+
+// InternalServerError returns a new 500 HTTPError.
+func InternalServerError(message string) *HTTPError {
+	return NewHTTPError(http.StatusInternalServerError, message)
+}
+
+// ServiceUnavailable returns a new 503 HTTPError.
+func ServiceUnavailable(message string) *HTTPError {
+	return NewHTTPError(http.StatusServiceUnavailable, message)
+}
+
+// Errors documents the error responses of the operation
+// with the given status codes, using HTTPError as the
+// shared error schema.
+func Errors(statusCodes ...int) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		for _, code := range statusCodes {
+			o.Responses = append(o.Responses, &openapi.OperationResponse{
+				Code:        strconv.Itoa(code),
+				Description: http.StatusText(code),
+				Model:       HTTPError{},
+			})
+		}
+	}
+}
@@ -22,6 +22,12 @@ func main() {
 		Description: "example",
 		Version:     "1.0.0",
 	}, "json"))
+	z.DocsUI("docs", optizz.UIOptions{
+		Title:       "example",
+		SpecURL:     "/openapi.json",
+		DeepLinking: true,
+		TryItOut:    true,
+	})
 
 	app.Get("hello", func(ctx *fiber.Ctx) error {
 		return ctx.Format("hello")
@@ -0,0 +1,323 @@
+package optizz
+
+import (
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+)
+
+// Fields tags used by the form binding.
+const (
+	FormTag    = "form"
+	MaxSizeTag = "maxsize"
+	AcceptTag  = "accept"
+)
+
+const multipartMediaType = "multipart/form-data"
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// isFileField returns whether the struct field
+// binds the file parts of a multipart form.
+func isFileField(sf reflect.StructField) bool {
+	return sf.Type == fileHeaderType || sf.Type == fileHeadersType
+}
+
+// formFieldName returns the name of the form part
+// bound to the struct field, if any.
+func formFieldName(sf reflect.StructField) (string, bool) {
+	tag, ok := sf.Tag.Lookup(FormTag)
+	if !ok {
+		return "", false
+	}
+	name := strings.TrimSpace(strings.Split(tag, ",")[0])
+	if name == "" || name == "-" {
+		return "", false
+	}
+	return name, true
+}
+
+// bindFiles binds the file parts of the multipart form of
+// the request to the fields of the input object in of type
+// *multipart.FileHeader or []*multipart.FileHeader that have
+// a form tag. The size and the media type of the files are
+// checked against the maxsize and accept tags of the fields.
+func bindFiles(c *fiber.Ctx, v reflect.Value) error {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return err
+	}
+	return bindFileFields(v, form)
+}
+
+func bindFileFields(v reflect.Value, form *multipart.Form) error {
+	t := v.Type()
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		v = v.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		field := v.Field(i)
+
+		// Handle embedded fields with a recursive call.
+		if ft.Anonymous {
+			if field.Kind() == reflect.Ptr {
+				if field.IsNil() {
+					if !field.CanSet() {
+						continue
+					}
+					field.Set(reflect.New(field.Type().Elem()))
+				}
+			} else if field.CanAddr() {
+				field = field.Addr()
+			}
+			if field.Kind() == reflect.Ptr && field.Elem().Kind() == reflect.Struct {
+				if err := bindFileFields(field, form); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		name, ok := formFieldName(ft)
+		if !ok || !isFileField(ft) {
+			continue
+		}
+		files := form.File[name]
+		if len(files) == 0 {
+			continue
+		}
+		if ft.Type == fileHeaderType && len(files) > 1 {
+			return BindError{field: ft.Name, typ: t, message: "multiple files not supported"}
+		}
+		for _, fh := range files {
+			if err := checkFile(ft, fh); err != nil {
+				return BindError{field: ft.Name, typ: t, message: err.Error()}
+			}
+		}
+		if ft.Type == fileHeaderType {
+			field.Set(reflect.ValueOf(files[0]))
+		} else {
+			field.Set(reflect.ValueOf(files))
+		}
+	}
+	return nil
+}
+
+// checkFile checks the size and the media type of the file
+// against the maxsize and accept tags of the struct field.
+// The check runs once the form is parsed, the size of the
+// body read from the client is only limited by the
+// BodyLimit of the Fiber app.
+func checkFile(sf reflect.StructField, fh *multipart.FileHeader) error {
+	if tag := sf.Tag.Get(MaxSizeTag); tag != "" {
+		max, err := parseSize(tag)
+		if err != nil {
+			return fmt.Errorf("malformed %s tag: %s", MaxSizeTag, err)
+		}
+		if fh.Size > max {
+			return fmt.Errorf("file %s exceeds the maximum size of %s", fh.Filename, tag)
+		}
+	}
+	if tag := sf.Tag.Get(AcceptTag); tag != "" {
+		mt, _, err := mime.ParseMediaType(fh.Header.Get(fiber.HeaderContentType))
+		if err != nil {
+			mt = "application/octet-stream"
+		}
+		if !acceptsMediaType(strings.Split(tag, ","), mt) {
+			return fmt.Errorf("file %s has an unacceptable media type %s, %s=%s", fh.Filename, mt, AcceptTag, tag)
+		}
+	}
+	return nil
+}
+
+// acceptsMediaType returns whether the media type mt
+// matches one of the accepted media types, which may
+// use a wildcard subtype, such as image/*.
+func acceptsMediaType(accepted []string, mt string) bool {
+	for _, a := range accepted {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == mt || a == "*/*" {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSize parses a size in bytes with an optional
+// unit suffix, such as 512, 64KB, 10MB or 1GB.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"B", 1}} {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * mult, nil
+}
+
+// hasFormFields returns whether the input type t
+// has fields bound from a form, or only the files
+// of a multipart form if files is true.
+func hasFormFields(t reflect.Type, files bool) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			if hasFormFields(sf.Type, files) {
+				return true
+			}
+			continue
+		}
+		if _, ok := formFieldName(sf); ok && (!files || isFileField(sf)) {
+			return true
+		}
+	}
+	return false
+}
+
+// setFormRequestBody documents the request body of the
+// operation as a form, for an input type t that has form
+// fields. The types that have files are only accepted as
+// a multipart form, and the others as a URL-encoded form
+// too. The properties of the form schema are renamed after
+// the form tags of the fields, and the accepted media
+// types of the files are documented with the encoding
+// of their parts.
+func setFormRequestBody(api *openapi.OpenAPI, op *openapi.Operation, t reflect.Type) {
+	if op.RequestBody == nil || !hasFormFields(t, false) {
+		return
+	}
+	content, ok := op.RequestBody.Content[JSONMediaType]
+	if !ok {
+		return
+	}
+	schema := content.Schema.Schema
+	if ref := content.Schema.Reference; ref != nil {
+		name := strings.TrimPrefix(ref.Ref, "#/components/schemas/")
+		if sor, ok := api.Components.Schemas[name]; ok && sor != nil {
+			schema = sor.Schema
+		}
+	}
+	if !hasFormFields(t, true) {
+		// The JSON body keeps the schema named after the
+		// JSON names, the form has a copy of its own.
+		if schema == nil {
+			return
+		}
+		schema = copySchema(schema)
+		form := &openapi.MediaType{Schema: &openapi.SchemaOrRef{Schema: schema}}
+		op.RequestBody.Content[fiber.MIMEApplicationForm] = form
+		setFormProperties(api, schema, form, t)
+		return
+	}
+	delete(op.RequestBody.Content, JSONMediaType)
+	if schema == nil {
+		op.RequestBody.Content[multipartMediaType] = content
+		return
+	}
+	// The component schema may be shared with the JSON
+	// bodies and the responses of the same type, the
+	// form has a copy of its own.
+	schema = copySchema(schema)
+	form := &openapi.MediaType{Schema: &openapi.SchemaOrRef{Schema: schema}}
+	op.RequestBody.Content[multipartMediaType] = form
+	setFormProperties(api, schema, form, t)
+}
+
+// setFilesSchema sets the items of the array schema of a
+// []*multipart.FileHeader field to binary strings. The
+// generator documents the elements of the slice with the
+// schema of the struct, which is removed from the components.
+func setFilesSchema(api *openapi.OpenAPI, sor *openapi.SchemaOrRef) {
+	if sor == nil || sor.Schema == nil || sor.Items == nil {
+		return
+	}
+	if ref := sor.Items.Reference; ref != nil {
+		delete(api.Components.Schemas, strings.TrimPrefix(ref.Ref, "#/components/schemas/"))
+	}
+	sor.Items = &openapi.SchemaOrRef{Schema: &openapi.Schema{
+		Type:   "string",
+		Format: "binary",
+	}}
+}
+
+func setFormProperties(api *openapi.OpenAPI, schema *openapi.Schema, content *openapi.MediaType, t reflect.Type) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			setFormProperties(api, schema, content, sf.Type)
+			continue
+		}
+		name, ok := formFieldName(sf)
+		if !ok {
+			continue
+		}
+		// The generator names the properties
+		// of the body after the JSON names.
+		prop := jsonFieldName(sf)
+		if sor, ok := schema.Properties[prop]; ok && prop != name {
+			delete(schema.Properties, prop)
+			schema.Properties[name] = sor
+			for j, r := range schema.Required {
+				if r == prop {
+					schema.Required[j] = name
+				}
+			}
+			sort.Strings(schema.Required)
+		}
+		if sor, ok := schema.Properties[name]; ok && sor != nil && sf.Type == fileHeadersType {
+			// The property is shared with the
+			// schema the form was copied from.
+			cpy := *sor
+			if sor.Schema != nil {
+				s := *sor.Schema
+				cpy.Schema = &s
+			}
+			schema.Properties[name] = &cpy
+			setFilesSchema(api, &cpy)
+		}
+		if accept := sf.Tag.Get(AcceptTag); accept != "" && isFileField(sf) {
+			if content.Encoding == nil {
+				content.Encoding = make(map[string]*openapi.Encoding)
+			}
+			content.Encoding[name] = &openapi.Encoding{
+				ContentType: strings.Join(strings.Split(accept, ","), ", "),
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package optizz
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// H returns the optizz handler of the typed handler fn, which
+// binds the input In and renders the output Out with the given
+// status code. Unlike Handler, the signature of the handler is
+// checked at compile time and it is called without reflection.
+// In must be a struct type.
+func H[In, Out any](fn func(*fiber.Ctx, *In) (*Out, error), status int, infos ...OperationOption) *OptizzHandler {
+	hv, fName := typedHandler(fn)
+	in := inputType[In](fName)
+
+	call := func(c *fiber.Ctx, input reflect.Value) (interface{}, error) {
+		return fn(c, input.Interface().(*In))
+	}
+	return newHandler(hv, fName, in, reflect.TypeOf((*Out)(nil)).Elem(), status, call, infos)
+}
+
+// HIn is a variant of H for the handlers
+// that have an input but no output.
+func HIn[In any](fn func(*fiber.Ctx, *In) error, status int, infos ...OperationOption) *OptizzHandler {
+	hv, fName := typedHandler(fn)
+	in := inputType[In](fName)
+
+	call := func(c *fiber.Ctx, input reflect.Value) (interface{}, error) {
+		return nil, fn(c, input.Interface().(*In))
+	}
+	return newHandler(hv, fName, in, nil, status, call, infos)
+}
+
+// HOut is a variant of H for the handlers
+// that have an output but no input.
+func HOut[Out any](fn func(*fiber.Ctx) (*Out, error), status int, infos ...OperationOption) *OptizzHandler {
+	hv, fName := typedHandler(fn)
+
+	call := func(c *fiber.Ctx, _ reflect.Value) (interface{}, error) {
+		return fn(c)
+	}
+	return newHandler(hv, fName, nil, reflect.TypeOf((*Out)(nil)).Elem(), status, call, infos)
+}
+
+// typedHandler returns the value and the
+// unique name of the typed handler fn.
+func typedHandler(fn interface{}) (reflect.Value, string) {
+	hv := reflect.ValueOf(fn)
+	if hv.IsNil() {
+		panic("handler must not be nil")
+	}
+	return hv, fmt.Sprintf("%s_%s", runtime.FuncForPC(hv.Pointer()).Name(), uuid.Must(uuid.NewRandom()).String())
+}
+
+// inputType returns the input type In of the
+// typed handler with the given name.
+func inputType[In any](name string) reflect.Type {
+	t := reflect.TypeOf((*In)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf(
+			"invalid input type for handler %s, expected struct, got %v",
+			name, t,
+		))
+	}
+	return t
+}
@@ -1,11 +1,34 @@
 module github.com/thanapolr/optizz
 
-go 1.13
+go 1.18
 
 require (
 	github.com/gofiber/fiber/v2 v2.5.0
 	github.com/google/uuid v1.2.0
+	github.com/ugorji/go/codec v1.1.7
 	github.com/valyala/fasthttp v1.18.0
 	github.com/wI2L/fizz v0.15.0
 	gopkg.in/go-playground/validator.v9 v9.31.0
+	gopkg.in/yaml.v2 v2.2.7
+)
+
+require (
+	github.com/andybalholm/brotli v1.0.0 // indirect
+	github.com/gin-contrib/sse v0.1.0 // indirect
+	github.com/gin-gonic/gin v1.4.0 // indirect
+	github.com/go-playground/locales v0.13.0 // indirect
+	github.com/go-playground/universal-translator v0.17.0 // indirect
+	github.com/gofrs/uuid v3.2.0+incompatible // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/json-iterator/go v1.1.8 // indirect
+	github.com/klauspost/compress v1.10.7 // indirect
+	github.com/leodido/go-urn v1.2.0 // indirect
+	github.com/loopfz/gadgeto v0.9.0 // indirect
+	github.com/mattn/go-isatty v0.0.10 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.1 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/tcplisten v0.0.0-20161114210144-ceec8f93295a // indirect
+	golang.org/x/sys v0.0.0-20201210223839-7e3030f88018 // indirect
+	gopkg.in/go-playground/validator.v8 v8.18.2 // indirect
 )
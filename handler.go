@@ -1,20 +1,28 @@
 package optizz
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/wI2L/fizz/openapi"
+	"net/http"
 	"reflect"
 	"runtime"
-	"strconv"
-	"strings"
 )
 
 type OptizzHandler struct {
 	RouteInfo     *Route
 	OperationInfo *openapi.OperationInfo
-	Handler       fiber.Handler
+	// Handler executes the handler with the
+	// process-wide default hooks.
+	Handler fiber.Handler
+
+	// serve executes the handler with the
+	// configuration of the given scope.
+	serve func(*fiber.Ctx, *scope, *operationSpec) error
+	name  string
+	ext   *operationExt
 }
 
 // Optizz Handler is the wrapper of fiber.Handler with route and operation information.
@@ -30,81 +38,157 @@ func Handler(fiberHandler interface{}, status int, infos ...OperationOption) *Op
 	in := input(ht, fName)
 	out := output(ht, fName)
 
+	call := func(c *fiber.Ctx, input reflect.Value) (interface{}, error) {
+		// args contains the input parameters of the
+		// optic handler call.
+		args := []reflect.Value{reflect.ValueOf(c)}
+		if in != nil {
+			args = append(args, input)
+		}
+		// Call optic handler with the arguments
+		// and extract the returned values.
+		var err, val interface{}
+
+		ret := hv.Call(args)
+		if out != nil {
+			val = ret[0].Interface()
+			err = ret[1].Interface()
+		} else {
+			err = ret[0].Interface()
+		}
+		if err != nil {
+			return val, err.(error)
+		}
+		return val, nil
+	}
+	return newHandler(hv, fName, in, out, status, call, infos)
+}
+
+// newHandler returns the optizz handler of the handler func hv,
+// with the input and output types in and out, which may be nil.
+// The call func invokes the handler with the bound input.
+func newHandler(hv reflect.Value, fName string, in, out reflect.Type, status int,
+	call func(*fiber.Ctx, reflect.Value) (interface{}, error), infos []OperationOption) *OptizzHandler {
 	routeInfo := &Route{
 		defaultStatusCode: status,
 		handler:           hv,
-		handlerType:       ht,
+		handlerType:       hv.Type(),
 		inputType:         in,
 		outputType:        out,
 	}
 
-	oi := &openapi.OperationInfo{}
-	for _, info := range infos {
-		info(oi)
+	oi, ext := newOperationInfo(infos)
+
+	// Compile the binding plan of the input
+	// type ahead of the first request.
+	if in != nil {
+		planOf(in)
 	}
 
-	f := func(c *fiber.Ctx) error {
-		// funcIn contains the input parameters of the
-		// optic handler call.
-		args := []reflect.Value{reflect.ValueOf(c)}
+	f := func(c *fiber.Ctx, s *scope, spec *operationSpec) error {
+		if ext.consumes != nil {
+			c.Locals(ctxConsumes, ext.consumes)
+		} else if spec != nil && spec.op != nil && spec.op.RequestBody != nil {
+			// The operations that do not declare the media
+			// types they consume accept the documented ones.
+			c.Locals(ctxConsumes, bodyMediaTypes(spec.op.RequestBody))
+		}
+		if ext.produces != nil {
+			c.Locals(ctxProduces, ext.produces)
+		}
+		if ext.strict(s) {
+			c.Locals(ctxStrictJSON, true)
+		}
+		// Negotiate the media type of the response, read
+		// by the render hooks, and reject the requests that
+		// accept none of them before calling the handler.
+		if _, ok := negotiateResponse(c); !ok && out != nil {
+			s.handleError(c, NewHTTPError(http.StatusNotAcceptable, ""))
+			return nil
+		}
 
 		// Optic handler has custom input, handle
 		// binding.
+		var input reflect.Value
 		if in != nil {
-			input := reflect.New(in)
+			input = reflect.New(in)
 			// Bind the body with the hook.
-			if err := bindHook(c, input); err != nil {
-				handleError(c, BindError{message: err.Error(), typ: in})
-				return err
+			if err := s.bindHook()(c, input); err != nil {
+				var he *HTTPError
+				if errors.As(err, &he) {
+					s.handleError(c, err)
+					return nil
+				}
+				be, ok := err.(BindError)
+				if !ok {
+					be = BindError{message: err.Error(), typ: in}
+				}
+				s.handleError(c, be)
+				return nil
 			}
+			// The fields bound from the cookies are not
+			// set by the body, which could spoof them.
+			planOf(in).resetCookies(input.Elem())
 			// Bind query-parameters.
-			if err := bindQueryHook(c, input); err != nil {
-				handleError(c, err)
-				return err
+			if err := s.bindQueryHook()(c, input); err != nil {
+				s.handleError(c, err)
+				return nil
 			}
 			// Bind path arguments.
-			if err := bindPathHook(c, input); err != nil {
-				handleError(c, err)
-				return err
+			if err := s.bindPathHook()(c, input); err != nil {
+				s.handleError(c, err)
+				return nil
 			}
 			// Bind headers.
-			if err := bindHeaderHook(c, input); err != nil {
-				handleError(c, err)
-				return err
+			if err := s.bindHeaderHook()(c, input); err != nil {
+				s.handleError(c, err)
+				return nil
+			}
+			// Bind cookies.
+			if err := s.bindCookieHook()(c, input); err != nil {
+				s.handleError(c, err)
+				return nil
 			}
 			// validating query and path inputs if they have a validate tag
-			initValidator()
-			args = append(args, input)
-			if err := validatorObj.Struct(input.Interface()); err != nil {
-				handleError(c, BindError{message: err.Error(), validationErr: err})
-				return err
+			if err := s.validate().Struct(input.Interface()); err != nil {
+				s.handleError(c, BindError{message: err.Error(), validationErr: err, typ: in})
+				return nil
 			}
 		}
-		// Call optic handler with the arguments
-		// and extract the returned values.
-		var err, val interface{}
+		val, err := call(c, input)
 
-		ret := hv.Call(args)
-		if out != nil {
-			val = ret[0].Interface()
-			err = ret[1].Interface()
-		} else {
-			err = ret[0].Interface()
-		}
-		// Handle the error returned by the
-		// handler invocation, if any.
+		// Handle the error returned by the handler
+		// invocation, if any. The error is rendered
+		// with the hooks, and must not reach the
+		// error handler of the Fiber app, which
+		// would overwrite the response.
 		if err != nil {
-			handleError(c, err.(error))
-			return err.(error)
+			s.handleError(c, err)
+			return nil
 		}
-		renderHook(c, status, val)
+		// Check the output against the documented
+		// response if the validation is enabled.
+		if mode := s.responseValidation(); mode != ResponseValidationOff && val != nil {
+			if errs := validateResponse(s, spec, status, val); len(errs) != 0 {
+				if err := handleInvalidResponse(c, s, mode, spec, errs); err != nil {
+					s.handleError(c, err)
+					return nil
+				}
+			}
+		}
+		s.renderHook()(c, status, val)
 		return nil
 	}
 
 	return &OptizzHandler{
 		RouteInfo:     routeInfo,
 		OperationInfo: oi,
-		Handler:       f,
+		Handler: func(c *fiber.Ctx) error {
+			return f(c, nil, nil)
+		},
+		serve: f,
+		name:  fName,
+		ext:   ext,
 	}
 }
 
@@ -174,89 +258,73 @@ func output(ht reflect.Type, name string) reflect.Type {
 
 // bind binds the fields the fields of the input object in with
 // the values of the parameters extracted from the Fiber context.
-// It reads tag to know what to extract using the extractor func.
+// It executes the binding plan of the input type for the
+// location tag, using the extractor func.
 func bind(c *fiber.Ctx, v reflect.Value, tag string, extract extractor) error {
-	t := v.Type()
-
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+	plan := planOf(v.Type())
+	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
-	for i := 0; i < t.NumField(); i++ {
-		ft := t.Field(i)
-		field := v.Field(i)
+	params := plan.params[tag]
+	if len(params) == 0 {
+		return nil
+	}
+	plan.allocEmbedded(v)
 
-		// Handle embedded fields with a recursive call.
-		// If the field is a pointer, but is nil, we
-		// create a new value of the same type, or we
-		// take the existing memory address.
-		if ft.Anonymous {
-			if field.Kind() == reflect.Ptr {
-				if field.IsNil() {
-					field.Set(reflect.New(field.Type().Elem()))
-				}
-			} else {
-				if field.CanAddr() {
-					field = field.Addr()
-				}
-			}
-			err := bind(c, field, tag, extract)
-			if err != nil {
-				return err
-			}
-			continue
+	for _, pp := range params {
+		if pp.err != nil {
+			return BindError{field: pp.field, typ: pp.owner, message: pp.err.Error()}
 		}
-		tagValue := ft.Tag.Get(tag)
-		if tagValue == "" {
+		field, err := v.FieldByIndexErr(pp.index)
+		if err != nil {
 			continue
 		}
-		// Set-up context for extractors.
-		// Query.
-		c.Locals(ExplodeTag, true) // default
-		if explodeVal, ok := ft.Tag.Lookup(ExplodeTag); ok {
-			if explode, err := strconv.ParseBool(explodeVal); err == nil && !explode {
-				c.Locals(ExplodeTag, false)
+		// Query parameters with the deepObject style
+		// bind nested structs and maps.
+		if pp.deepObject {
+			if err := bindDeepObject(c, field, pp.name); err != nil {
+				return BindError{field: pp.field, typ: pp.owner, message: err.Error()}
 			}
+			continue
 		}
-		_, fieldValues, err := extract(c, tagValue)
+		fieldValues, err := extract(c, pp)
 		if err != nil {
-			return BindError{field: ft.Name, typ: t, message: err.Error()}
+			return BindError{field: pp.field, typ: pp.owner, message: err.Error()}
 		}
-		// Extract default value and use it in place
+		// Use the default values in place
 		// if no values were returned.
-		def, ok := ft.Tag.Lookup(DefaultTag)
-		if ok && len(fieldValues) == 0 {
-			if b, ok := c.Locals(ExplodeTag).(bool); ok && b {
-				fieldValues = append(fieldValues, strings.Split(def, ",")...)
-			} else {
-				fieldValues = append(fieldValues, def)
-			}
+		if len(fieldValues) == 0 {
+			fieldValues = pp.defaults
 		}
 		if len(fieldValues) == 0 {
 			continue
 		}
+		// Ensure that the field is addressable and wasn't
+		// obtained by the use of an unexported struct
+		// field, or calling a setter will panic.
+		if !field.CanSet() {
+			return BindError{field: pp.field, typ: pp.owner, message: fmt.Sprintf("unaddressable value: %v", field)}
+		}
 		// If the field is a nil pointer to a concrete type,
 		// create a new addressable value for this type.
-		if field.Kind() == reflect.Ptr && field.IsNil() {
-			f := reflect.New(field.Type().Elem())
-			field.Set(f)
-		}
-		// Dereference pointer.
 		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				field.Set(reflect.New(field.Type().Elem()))
+			}
 			field = field.Elem()
 		}
-		kind := field.Kind()
+		kind := pp.kind
 
 		// Multiple values can only be filled to types
 		// Slice and Array.
 		if len(fieldValues) > 1 && (kind != reflect.Slice && kind != reflect.Array) {
-			return BindError{field: ft.Name, typ: t, message: "multiple values not supported"}
+			return BindError{field: pp.field, typ: pp.owner, message: "multiple values not supported"}
 		}
 		// Ensure that the number of values to fill does
 		// not exceed the length of a field of type Array.
 		if kind == reflect.Array {
 			if field.Len() != len(fieldValues) {
-				return BindError{field: ft.Name, typ: t, message: fmt.Sprintf(
+				return BindError{field: pp.field, typ: pp.owner, message: fmt.Sprintf(
 					"parameter expect %d values, got %d", field.Len(), len(fieldValues)),
 				}
 			}
@@ -265,64 +333,38 @@ func bind(c *fiber.Ctx, v reflect.Value, tag string, extract extractor) error {
 			// Create a new slice with an adequate
 			// length to set all the values.
 			if kind == reflect.Slice {
-				field.Set(reflect.MakeSlice(field.Type(), 0, len(fieldValues)))
+				field.Set(reflect.MakeSlice(field.Type(), len(fieldValues), len(fieldValues)))
 			}
 			for i, val := range fieldValues {
-				v := reflect.New(field.Type().Elem()).Elem()
-				err = bindStringValue(val, v)
-				if err != nil {
-					return BindError{field: ft.Name, typ: t, message: err.Error()}
-				}
-				if kind == reflect.Slice {
-					field.Set(reflect.Append(field, v))
-				}
-				if kind == reflect.Array {
-					field.Index(i).Set(v)
+				if err := pp.decode(val, field.Index(i)); err != nil {
+					return BindError{field: pp.field, typ: pp.owner, message: err.Error()}
 				}
 			}
 			continue
 		}
 		// Handle enum values.
-		enum := ft.Tag.Get(EnumTag)
-		if enum != "" {
-			enumValues := strings.Split(strings.TrimSpace(enum), ",")
-			if len(enumValues) != 0 {
-				if !contains(enumValues, fieldValues[0]) {
-					return BindError{field: ft.Name, typ: t, message: fmt.Sprintf(
-						"parameter has not an acceptable value, %s=%v", EnumTag, enumValues),
-					}
-				}
+		if len(pp.enum) != 0 && !contains(pp.enum, fieldValues[0]) {
+			return BindError{field: pp.field, typ: pp.owner, message: fmt.Sprintf(
+				"parameter has not an acceptable value, %s=%v", EnumTag, pp.enum),
 			}
 		}
 		// Fill string value into input field.
-		err = bindStringValue(fieldValues[0], field)
-		if err != nil {
-			return BindError{field: ft.Name, typ: t, message: err.Error()}
+		if err := pp.decode(fieldValues[0], field); err != nil {
+			return BindError{field: pp.field, typ: pp.owner, message: err.Error()}
 		}
 	}
 	return nil
 }
 
-// handleError handles any error raised during the execution
-// of the wrapping Fiber-handler.
-func handleError(c *fiber.Ctx, err error) {
-	var errors []error
-	_errs := c.Locals("_errors_")
-	if _errs == nil {
-		errors = make([]error, 0)
-	} else {
-		if _errors, ok := _errs.([]error); ok {
-			errors = _errors
-		} else {
-			errors = make([]error, 0)
+// isBodyField returns whether the struct field is bound
+// from the body, rather than from a request parameter.
+func isBodyField(sf reflect.StructField) bool {
+	for _, tag := range []string{QueryTag, PathTag, HeaderTag, CookieTag} {
+		if _, ok := sf.Tag.Lookup(tag); ok {
+			return false
 		}
 	}
-
-	errors = append(errors, err)
-	c.Locals("_errors_", errors)
-
-	code, resp := errorHook(c, err)
-	renderHook(c, code, resp)
+	return true
 }
 
 // contains returns whether in contain s.
@@ -0,0 +1,365 @@
+package optizz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/wI2L/fizz/openapi"
+)
+
+// MountOption represents an option-pattern function
+// used to configure the mounting of an instance.
+type MountOption func(*mountOptions)
+
+type mountOptions struct {
+	renameSchemas bool
+}
+
+// RenameSchemas renames the schemas of the mounted instance
+// that collide with different schemas of the same name,
+// after the mount prefix, such as BillingInvoice for the
+// Invoice schema mounted on /billing. By default, such
+// collisions are errors.
+func RenameSchemas() MountOption {
+	return func(o *mountOptions) {
+		o.renameSchemas = true
+	}
+}
+
+// Mount mounts the routes of the other instance on the Fiber app
+// under the prefix, and merges its specification: the paths,
+// prefixed, the components, the tags, the security schemes and
+// the security requirements. Its routes are added to the registry
+// of the instance. The instance is mounted as is, so the routes
+// registered on it afterwards are neither served nor documented.
+// Its routes enforce their security requirements with the
+// authenticators of the instance, unless it has its own.
+//
+// It returns an error, before mounting anything, if a path or an
+// operation ID of the other instance is already documented, or if
+// one of its components is documented with a different definition,
+// unless the schemas are renamed with the RenameSchemas option.
+func (f *Optizz) Mount(prefix string, other *Optizz, opts ...MountOption) error {
+	if other == nil || other == f {
+		return errors.New("invalid instance to mount")
+	}
+	mo := &mountOptions{}
+	for _, opt := range opts {
+		opt(mo)
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	// Work on a copy of the specification of the other
+	// instance, which is left untouched.
+	b, err := json.Marshal(other.gen.API())
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	api := f.gen.API()
+
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	renames, err := schemaRenames(api.Components.Schemas, schemas, prefix, mo.renameSchemas)
+	if err != nil {
+		return err
+	}
+	if len(renames) != 0 {
+		for name, renamed := range renames {
+			schemas[renamed] = schemas[name]
+			delete(schemas, name)
+		}
+		renameRefs(doc, renames, 0)
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		return err
+	}
+	var mounted openapi.OpenAPI
+	if err := json.Unmarshal(b, &mounted); err != nil {
+		return err
+	}
+	if err := f.checkMount(prefix, &mounted, other); err != nil {
+		return err
+	}
+	// Merge the specification.
+	if c := mounted.Components; c != nil {
+		copyComponents(c.Schemas, &api.Components.Schemas)
+		copyComponents(c.Responses, &api.Components.Responses)
+		copyComponents(c.Parameters, &api.Components.Parameters)
+		copyComponents(c.Examples, &api.Components.Examples)
+		copyComponents(c.Headers, &api.Components.Headers)
+	}
+	// The servers of the other instance remain
+	// those of its paths, if they differ.
+	servers := mounted.Servers
+	if equalJSON(servers, api.Servers) {
+		servers = nil
+	}
+	for path, item := range mounted.Paths {
+		if item == nil {
+			continue
+		}
+		if item.Servers == nil && len(servers) != 0 {
+			item.Servers = servers
+		}
+		api.Paths[joinPaths(prefix, path)] = item
+	}
+	for _, t := range mounted.Tags {
+		if t == nil || hasTag(api, t.Name) {
+			continue
+		}
+		f.gen.AddTag(t.Name, t.Description)
+	}
+	// Merge the extensions.
+	for name, deep := range other.specExt.closedSchemas {
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+		}
+		f.specExt.closedSchemas[name] = f.specExt.closedSchemas[name] || deep
+	}
+	for _, tg := range other.specExt.tagGroups {
+		for _, t := range tg.tags {
+			f.specExt.addTagGroup(tg.name, t)
+		}
+	}
+	for name, scheme := range other.specExt.securitySchemes {
+		f.specExt.securitySchemes[name] = scheme
+	}
+	for key, reqs := range other.specExt.security {
+		f.specExt.security[operationKey{path: joinPaths(prefix, key.path), method: key.method}] = reqs
+	}
+	for key, route := range other.opRoutes {
+		f.opRoutes[operationKey{path: joinPaths(prefix, key.path), method: key.method}] = route
+	}
+	f.specExt.mergeContract(other.specExt, prefix, renames)
+	f.registry.merge(other.registry, prefix)
+	other.auth.mu.Lock()
+	other.auth.parent = f
+	other.auth.mu.Unlock()
+	f.app.Mount(prefix, other.app)
+	f.spec.invalidate()
+
+	return nil
+}
+
+// mergeContract merges the contract of the extensions
+// other, mounted under the prefix, whose schemas are
+// renamed after renames.
+func (se *specExtensions) mergeContract(other *specExtensions, prefix string, renames map[string]string) {
+	if len(other.contractPaths) != 0 && se.contractPaths == nil {
+		se.contractPaths = make(map[string]interface{}, len(other.contractPaths))
+	}
+	for path, item := range other.contractPaths {
+		item = copyValue(item)
+		renameRefs(item, renames, 0)
+		se.contractPaths[joinPaths(prefix, path)] = item
+	}
+	if len(other.contractComponents) != 0 && se.contractComponents == nil {
+		se.contractComponents = make(map[string]interface{}, len(other.contractComponents))
+	}
+	for kind, v := range other.contractComponents {
+		src, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		dst, _ := se.contractComponents[kind].(map[string]interface{})
+		if dst == nil {
+			dst = make(map[string]interface{}, len(src))
+			se.contractComponents[kind] = dst
+		}
+		for name, c := range src {
+			c = copyValue(c)
+			renameRefs(c, renames, 0)
+			if renamed, ok := renames[name]; ok && kind == "schemas" {
+				name = renamed
+			}
+			dst[name] = c
+		}
+	}
+}
+
+// checkMount returns an error if the specification mounted,
+// from the other instance, conflicts with the specification
+// of the instance.
+func (f *Optizz) checkMount(prefix string, mounted *openapi.OpenAPI, other *Optizz) error {
+	api := f.gen.API()
+
+	ids := make(map[string]bool)
+	for _, item := range api.Paths {
+		for _, method := range pathMethods {
+			if op := pathOperationOf(item, method); op != nil && op.ID != "" {
+				ids[op.ID] = true
+			}
+		}
+	}
+	for path, item := range mounted.Paths {
+		if _, ok := api.Paths[joinPaths(prefix, path)]; ok {
+			return fmt.Errorf("path %s is already documented", joinPaths(prefix, path))
+		}
+		for _, method := range pathMethods {
+			if op := pathOperationOf(item, method); op != nil && ids[op.ID] {
+				return fmt.Errorf("operation ID %s is already documented", op.ID)
+			}
+		}
+	}
+	if c := mounted.Components; c != nil {
+		for _, conflict := range []string{
+			conflictingComponent("schema", api.Components.Schemas, c.Schemas),
+			conflictingComponent("response", api.Components.Responses, c.Responses),
+			conflictingComponent("parameter", api.Components.Parameters, c.Parameters),
+			conflictingComponent("example", api.Components.Examples, c.Examples),
+			conflictingComponent("header", api.Components.Headers, c.Headers),
+		} {
+			if conflict != "" {
+				return errors.New(conflict)
+			}
+		}
+	}
+	if conflict := conflictingComponent("security scheme", f.specExt.securitySchemes, other.specExt.securitySchemes); conflict != "" {
+		return errors.New(conflict)
+	}
+	return nil
+}
+
+// pathOperationOf is pathOperation for
+// the path items that may be nil.
+func pathOperationOf(item *openapi.PathItem, method string) *openapi.Operation {
+	if item == nil {
+		return nil
+	}
+	return pathOperation(item, method)
+}
+
+// conflictingComponent returns the message of the conflict
+// between the components of src and the components of
+// the same name of dst with a different definition.
+func conflictingComponent[T any](kind string, dst, src map[string]T) string {
+	names := make([]string, 0, len(src))
+	for name := range src {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if c, ok := dst[name]; ok && !equalJSON(c, src[name]) {
+			return fmt.Sprintf("%s %s is already documented with a different definition", kind, name)
+		}
+	}
+	return ""
+}
+
+// schemaRenames returns the new names of the generic schemas
+// of src that collide with the different schemas of dst.
+func schemaRenames(dst map[string]*openapi.SchemaOrRef, src map[string]interface{}, prefix string, rename bool) (map[string]string, error) {
+	names := make([]string, 0, len(src))
+	for name := range src {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renames := make(map[string]string)
+	for _, name := range names {
+		s, ok := dst[name]
+		if !ok || equalJSON(s, src[name]) {
+			continue
+		}
+		if !rename {
+			return nil, fmt.Errorf("schema %s is already documented with a different definition", name)
+		}
+		base := schemaPrefix(prefix) + name
+		renamed := base
+		for i := 2; ; i++ {
+			_, inDst := dst[renamed]
+			_, inSrc := src[renamed]
+			if !inDst && !inSrc {
+				break
+			}
+			renamed = fmt.Sprintf("%s%d", base, i)
+		}
+		renames[name] = renamed
+		// Reserve the new name.
+		src[renamed] = nil
+	}
+	for _, renamed := range renames {
+		delete(src, renamed)
+	}
+	return renames, nil
+}
+
+// schemaPrefix returns the prefix of the renamed schemas
+// for the mount prefix, such as BillingV1 for /billing/v1.
+func schemaPrefix(prefix string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(prefix, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		b.WriteString(string(r))
+	}
+	if b.Len() == 0 {
+		return "Mounted"
+	}
+	return b.String()
+}
+
+// renameRefs renames the references to the component
+// schemas of the generic specification v.
+func renameRefs(v interface{}, renames map[string]string, depth int) {
+	if depth > 64 {
+		return
+	}
+	switch val := v.(type) {
+	case []interface{}:
+		for _, e := range val {
+			renameRefs(e, renames, depth+1)
+		}
+	case map[string]interface{}:
+		if ref, ok := val["$ref"].(string); ok {
+			name := strings.TrimPrefix(ref, "#/components/schemas/")
+			if renamed, ok := renames[name]; ok && name != ref {
+				val["$ref"] = "#/components/schemas/" + renamed
+			}
+		}
+		for _, e := range val {
+			renameRefs(e, renames, depth+1)
+		}
+	}
+}
+
+// equalJSON returns whether a and b
+// have the same JSON representation.
+func equalJSON(a, b interface{}) bool {
+	ba, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	var va, vb interface{}
+	if json.Unmarshal(ba, &va) != nil || json.Unmarshal(bb, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// hasTag returns whether the tag with the given
+// name is documented by the specification api.
+func hasTag(api *openapi.OpenAPI, name string) bool {
+	for _, t := range api.Tags {
+		if t != nil && t.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package optizz
+
+import (
+	"github.com/wI2L/fizz/openapi"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// Option represents an option-pattern function used
+// to configure an Optizz instance at construction.
+type Option func(*options)
+
+// options holds the configuration of an Optizz
+// instance applied at construction.
+type options struct {
+	specConfig *openapi.SpecGenConfig
+	validator  *validator.Validate
+	hooks      Hooks
+	servers    []*openapi.Server
+	info       *openapi.Info
+	docs       []docsRoute
+	strictJSON *bool
+	responses  *ResponseValidation
+	logger     Logger
+}
+
+// docsRoute represents a documentation UI
+// mounted at construction.
+type docsRoute struct {
+	prefix string
+	opts   UIOptions
+}
+
+// defaultSpecConfig returns the configuration of the
+// spec generator based on the binding tags.
+func defaultSpecConfig() *openapi.SpecGenConfig {
+	return &openapi.SpecGenConfig{
+		ValidatorTag:      ValidationTag,
+		PathLocationTag:   PathTag,
+		QueryLocationTag:  QueryTag,
+		HeaderLocationTag: HeaderTag,
+		EnumTag:           EnumTag,
+		DefaultTag:        DefaultTag,
+	}
+}
+
+// WithSpecConfig sets the configuration of the OpenAPI
+// spec generator. The location tags of the configuration
+// should match the tags used by the binding hooks.
+func WithSpecConfig(conf *openapi.SpecGenConfig) Option {
+	return func(o *options) {
+		if conf != nil {
+			o.specConfig = conf
+		}
+	}
+}
+
+// WithValidator sets the validator used to validate
+// the input of the handlers of the instance, in place
+// of the package validator. It should use ValidationTag
+// as tag name to match the generated specification.
+func WithValidator(v *validator.Validate) Option {
+	return func(o *options) {
+		o.validator = v
+	}
+}
+
+// WithHooks sets the hooks used by the handlers of
+// the instance. Nil hooks use the process-wide defaults.
+func WithHooks(h Hooks) Option {
+	return func(o *options) {
+		o.hooks = h
+	}
+}
+
+// WithErrorHook sets the error hook used by the
+// handlers of the instance.
+func WithErrorHook(eh ErrorHook) Option {
+	return func(o *options) {
+		o.hooks.Error = eh
+	}
+}
+
+// WithRenderHook sets the render hook used by the
+// handlers of the instance.
+func WithRenderHook(rh RenderHook) Option {
+	return func(o *options) {
+		o.hooks.Render = rh
+	}
+}
+
+// WithStrictJSON enables the strict decoding of the
+// JSON bodies of the handlers of the instance, which
+// rejects unknown fields and trailing data. It can be
+// overridden per handler with the StrictJSON option.
+func WithStrictJSON(strict bool) Option {
+	return func(o *options) {
+		o.strictJSON = &strict
+	}
+}
+
+// WithResponseValidation sets the mode of the validation
+// of the outputs of the handlers against their validate
+// tags and the schema of their documented response.
+// It is meant to catch the drifts between the handlers
+// and their documentation during development.
+func WithResponseValidation(mode ResponseValidation) Option {
+	return func(o *options) {
+		o.responses = &mode
+	}
+}
+
+// Logger is the interface of the logger of an
+// Optizz instance, implemented by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger of the instance, which
+// reports the responses that do not match their schema.
+// It defaults to the standard logger.
+func WithLogger(l Logger) Option {
+	return func(o *options) {
+		o.logger = l
+	}
+}
+
+// WithServers sets the servers list of the
+// OpenAPI specification.
+func WithServers(servers ...*openapi.Server) Option {
+	return func(o *options) {
+		o.servers = append(o.servers, servers...)
+	}
+}
+
+// WithInfo sets the info of the OpenAPI specification.
+func WithInfo(info *openapi.Info) Option {
+	return func(o *options) {
+		o.info = info
+	}
+}
+
+// WithDocsUI mounts a documentation UI at the given
+// prefix, see Optizz.DocsUI.
+func WithDocsUI(prefix string, opts UIOptions) Option {
+	return func(o *options) {
+		o.docs = append(o.docs, docsRoute{prefix: prefix, opts: opts})
+	}
+}
@@ -4,13 +4,24 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"mime/multipart"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wI2L/fizz/openapi"
 )
 
-const ctxOpenAPIOperation = "_ctx_openapi_operation"
+const (
+	ctxOpenAPIOperation = "_ctx_openapi_operation"
+	ctxConsumes         = "_ctx_consumes"
+	ctxProduces         = "_ctx_produces"
+	ctxMediaType        = "_ctx_media_type"
+	ctxStrictJSON       = "_ctx_strict_json"
+	ctxPrincipals       = "_ctx_principals"
+	yamlMediaType       = "application/x-yaml"
+)
 
 // Primitive type helpers.
 var (
@@ -29,44 +40,79 @@ var (
 // routes handlers with Tonic and generates an OpenAPI
 // 3.0 specification from it.
 type Optizz struct {
-	gen *openapi.Generator
-	app *fiber.App
+	gen      *openapi.Generator
+	app      *fiber.App
+	spec     *specCache
+	specExt  *specExtensions
+	routes   *routeIndex
+	contract *contract
+	registry *routeRegistry
+	auth     *authentication
+	groupOps []*groupOperation
+	opRoutes map[operationKey]*operationRoute
 	*RouterGroup
 }
 
 
 // New creates a new Fizz wrapper for
 // a default Fiber app.
-func New() *Optizz {
-	return NewFromApp(fiber.New())
+func New(opts ...Option) *Optizz {
+	return NewFromApp(fiber.New(), opts...)
 }
 
 // NewFromApp creates a new Fizz wrapper
 // from an existing Fiber app.
-func NewFromApp(app *fiber.App) *Optizz {
+func NewFromApp(app *fiber.App, opts ...Option) *Optizz {
+	o := &options{
+		specConfig: defaultSpecConfig(),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
 	// Create a new spec with the config
 	// based on tonic internals.
-	gen, _ := openapi.NewGenerator(
-		&openapi.SpecGenConfig{
-			ValidatorTag:      ValidationTag,
-			PathLocationTag:   PathTag,
-			QueryLocationTag:  QueryTag,
-			HeaderLocationTag: HeaderTag,
-			EnumTag:           EnumTag,
-			DefaultTag:        DefaultTag,
-		},
-	)
-
-	return &Optizz{
-		app: app,
-		gen: gen,
-		RouterGroup: &RouterGroup{
-			app:   app,
-			group: app.Group(""),
-			gen:   gen,
-			path:  "",
-		},
+	gen, err := openapi.NewGenerator(o.specConfig)
+	if err != nil {
+		panic(fmt.Sprintf("error while creating the OpenAPI generator: %s", err))
+	}
+	if o.info != nil {
+		gen.SetInfo(o.info)
+	}
+	if o.servers != nil {
+		gen.SetServers(o.servers)
+	}
+	// Files of multipart forms are documented
+	// as binary strings.
+	_ = gen.OverrideDataType(reflect.TypeOf(multipart.FileHeader{}), "string", "binary")
+	root := newScope(nil)
+	root.setHooks(o.hooks)
+	root.validator = o.validator
+	root.strictJSON = o.strictJSON
+	root.responses = o.responses
+	root.logger = o.logger
+
+	f := &Optizz{
+		app:      app,
+		gen:      gen,
+		spec:     newSpecCache(),
+		specExt:  newSpecExtensions(),
+		routes:   &routeIndex{},
+		registry: newRouteRegistry(),
+		opRoutes: make(map[operationKey]*operationRoute),
+		auth:     &authentication{},
+	}
+	f.RouterGroup = &RouterGroup{
+		app:   app,
+		root:  f,
+		group: app.Group(""),
+		gen:   gen,
+		scope: root,
+		path:  "",
 	}
+	for _, d := range o.docs {
+		f.DocsUI(d.prefix, d.opts)
+	}
+	return f
 }
 
 // App returns the underlying Fiber app.
@@ -88,32 +134,176 @@ func (f *Optizz) Errors() []error {
 
 // OpenAPI returns a Fiber HandlerFunc that serves
 // the marshalled OpenAPI specification of the API.
+// A nil info keeps the info set with WithInfo.
+// The content type ct is either "json", "yaml" or "auto".
+// With "auto", the format is picked per request from the
+// "format" query parameter, or from the Accept header.
 func (f *Optizz) OpenAPI(info *openapi.Info, ct string) fiber.Handler {
-	f.gen.SetInfo(info)
+	if info != nil {
+		f.gen.SetInfo(info)
+		f.spec.invalidate()
+	}
 
 	ct = strings.ToLower(ct)
 	if ct == "" {
 		ct = "json"
 	}
 	switch ct {
-	case "json":
+	case "json", "yaml":
 		return func(c *fiber.Ctx) error {
-			c.Status(200)
-			c.JSON(f.gen.API())
-			return nil
+			return f.renderSpec(c, ct)
+		}
+	case "auto":
+		return func(c *fiber.Ctx) error {
+			return f.renderSpec(c, specFormat(c))
 		}
-		//case "yaml":
-		//	return func(c *fiber.Ctx) error {
-		//		c.YAML(200, f.gen.API())
-		//	}
 	}
-	panic("invalid content type, use JSON or YAML")
+	panic("invalid content type, use JSON, YAML or auto")
+}
+
+// specFormat returns the format of the specification
+// requested by the client, "json" or "yaml".
+func specFormat(c *fiber.Ctx) string {
+	switch strings.ToLower(c.Query("format")) {
+	case "json":
+		return "json"
+	case "yaml", "yml":
+		return "yaml"
+	}
+	switch c.Accepts(fiber.MIMEApplicationJSON, yamlMediaType, "application/yaml", "text/yaml") {
+	case yamlMediaType, "application/yaml", "text/yaml":
+		return "yaml"
+	}
+	return "json"
 }
 
 // OperationOption represents an option-pattern function
 // used to add informations to an operation.
 type OperationOption func(*openapi.OperationInfo)
 
+// operationExt holds the options of an operation
+// that have no field in openapi.OperationInfo.
+type operationExt struct {
+	consumes   []string
+	produces   []string
+	strictJSON *bool
+
+	// tags replace the tags of the group if set,
+	// and addTags are added to them.
+	tags    []string
+	setTags bool
+	addTags []string
+
+	// security are the security requirements of
+	// the operation, nil if not set.
+	security []SecurityRequirement
+}
+
+// The extended options of the handlers being created are
+// recorded by their operation info while their options
+// are applied only, see newOperationInfo. The handlers
+// keep them in their ext field afterwards.
+var (
+	opExtsMu sync.Mutex
+	opExts   = make(map[*openapi.OperationInfo]*operationExt)
+)
+
+// newOperationInfo returns the operation info and the
+// extended options set by the operation options infos.
+func newOperationInfo(infos []OperationOption) (*openapi.OperationInfo, *operationExt) {
+	oi, ext := &openapi.OperationInfo{}, &operationExt{}
+
+	opExtsMu.Lock()
+	opExts[oi] = ext
+	opExtsMu.Unlock()
+
+	defer func() {
+		opExtsMu.Lock()
+		delete(opExts, oi)
+		opExtsMu.Unlock()
+	}()
+	for _, info := range infos {
+		info(oi)
+	}
+	return oi, ext
+}
+
+// extOf returns the extended options of the operation
+// info o. It panics if o is not the info of a handler
+// being created, rather than discarding the options:
+// they must be passed to the handler.
+func extOf(o *openapi.OperationInfo) *operationExt {
+	opExtsMu.Lock()
+	ext, ok := opExts[o]
+	opExtsMu.Unlock()
+
+	if !ok {
+		panic("extended operation options must be passed to the handler, not applied to its operation info")
+	}
+	return ext
+}
+
+// strict returns whether the JSON body of the operation
+// is decoded in strict mode, defaulting to the mode
+// of the scope.
+func (ext *operationExt) strict(s *scope) bool {
+	if ext.strictJSON != nil {
+		return *ext.strictJSON
+	}
+	return s.strict()
+}
+
+// Consumes sets the media types of the request body
+// of the operation. Bodies of other media types are
+// rejected with a 415. By default, the media types
+// documented for the request body are accepted, which
+// is JSON, or the forms for the input types with
+// form fields.
+func Consumes(mediaTypes ...string) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		ext := extOf(o)
+		ext.consumes = append(ext.consumes, mediaTypes...)
+	}
+}
+
+// Produces sets the media types of the responses of
+// the operation, in order of preference. Requests that
+// accept none of them are rejected with a 406. By default,
+// the responses are JSON, as documented.
+func Produces(mediaTypes ...string) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		ext := extOf(o)
+		ext.produces = append(ext.produces, mediaTypes...)
+	}
+}
+
+// StrictJSON overrides the strict decoding of the JSON
+// body of the operation set with the WithStrictJSON option.
+func StrictJSON(strict bool) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		extOf(o).strictJSON = &strict
+	}
+}
+
+// Tags sets the tags of the operation, in place
+// of the tags of its group.
+func Tags(tags ...string) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		ext := extOf(o)
+		ext.tags = append(ext.tags, tags...)
+		ext.setTags = true
+	}
+}
+
+// AddTags adds tags to the operation, in
+// addition to the tags of its group.
+func AddTags(tags ...string) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		ext := extOf(o)
+		ext.addTags = append(ext.addTags, tags...)
+	}
+}
+
 // StatusDescription sets the default status description of the operation.
 func StatusDescription(desc string) func(*openapi.OperationInfo) {
 	return func(o *openapi.OperationInfo) {
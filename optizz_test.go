@@ -1,10 +1,25 @@
 package optizz
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/valyala/fasthttp"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/wI2L/fizz/openapi"
+	"gopkg.in/yaml.v2"
 )
 
 func BenchmarkFiber_App(b *testing.B) {
@@ -51,6 +66,89 @@ func BenchmarkOptizz_CallHandler(b *testing.B) {
 	}
 }
 
+func BenchmarkOptizz_CallHandlerWithOutput(b *testing.B) {
+	app := fiber.New()
+
+	h := Handler(func(c *fiber.Ctx) (*struct{}, error) { return &struct{}{}, nil }, 200)
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	for n := 0; n < b.N; n++ {
+		h.Handler(ctx)
+	}
+}
+
+func BenchmarkOptizz_CallTypedHandler(b *testing.B) {
+	app := fiber.New()
+
+	h := HOut(func(c *fiber.Ctx) (*struct{}, error) { return &struct{}{}, nil }, 200)
+	ctx := app.AcquireCtx(&fasthttp.RequestCtx{})
+	defer app.ReleaseCtx(ctx)
+
+	for n := 0; n < b.N; n++ {
+		h.Handler(ctx)
+	}
+}
+
+type benchBindInput struct {
+	ID     int      `query:"id" validate:"required"`
+	Tags   []string `query:"tags"`
+	Sort   string   `query:"sort" default:"asc" enum:"asc,desc"`
+	Limit  *int     `query:"limit" default:"20"`
+	Token  string   `header:"X-Token"`
+	Name   string   `json:"name" validate:"required"`
+	Active bool     `json:"active"`
+}
+
+func benchBindCtx(app *fiber.App) *fiber.Ctx {
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetMethod(fiber.MethodPost)
+	fctx.Request.SetRequestURI("/bench?id=42&tags=a&tags=b&tags=c&sort=desc")
+	fctx.Request.Header.Set("X-Token", "secret")
+	fctx.Request.Header.SetContentType(fiber.MIMEApplicationJSON)
+	fctx.Request.SetBody([]byte(`{"name":"bench","active":true}`))
+
+	return app.AcquireCtx(fctx)
+}
+
+func BenchmarkOptizz_CallHandlerWithBinding(b *testing.B) {
+	app := fiber.New()
+
+	h := Handler(func(c *fiber.Ctx, in *benchBindInput) error { return nil }, 200)
+	ctx := benchBindCtx(app)
+	defer app.ReleaseCtx(ctx)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		h.Handler(ctx)
+	}
+}
+
+func BenchmarkOptizz_CallTypedHandlerWithBinding(b *testing.B) {
+	app := fiber.New()
+
+	h := HIn(func(c *fiber.Ctx, in *benchBindInput) error { return nil }, 200)
+	ctx := benchBindCtx(app)
+	defer app.ReleaseCtx(ctx)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		h.Handler(ctx)
+	}
+}
+
+func BenchmarkOptizz_BindQuery(b *testing.B) {
+	app := fiber.New()
+
+	ctx := benchBindCtx(app)
+	defer app.ReleaseCtx(ctx)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		DefaultBindQueryHook(ctx, reflect.ValueOf(&benchBindInput{}))
+	}
+}
+
 func BenchmarkFiber_CallHandler(b *testing.B) {
 	app := fiber.New()
 
@@ -63,4 +161,2910 @@ func BenchmarkFiber_CallHandler(b *testing.B) {
 	}
 }
 
+// testRequest is a request sent to
+// an app by the table tests.
+type testRequest struct {
+	method string
+	target string
+	header map[string]string
+	body   string
+}
+
+// serve sends the request r to the app and returns
+// the response and its body.
+func serve(t *testing.T, app *fiber.App, r testRequest) (*http.Response, string) {
+	t.Helper()
+
+	method := r.method
+	if method == "" {
+		method = fiber.MethodGet
+	}
+	var body io.Reader
+	if r.body != "" {
+		body = strings.NewReader(r.body)
+	}
+	req := httptest.NewRequest(method, r.target, body)
+	for k, v := range r.header {
+		req.Header.Set(k, v)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, r.target, err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("%s %s: %s", method, r.target, err)
+	}
+	return resp, string(b)
+}
+
+func TestOptizz_OpenAPI(t *testing.T) {
+	f := New()
+	f.Get("/pets", Handler(func(c *fiber.Ctx) error { return nil }, 200, ID("listPets")))
+	f.App().Get("/openapi.json", f.OpenAPI(&openapi.Info{Title: "Pets", Version: "1.0.0"}, "json"))
+	f.App().Get("/openapi.yaml", f.OpenAPI(nil, "yaml"))
+	f.App().Get("/openapi", f.OpenAPI(nil, "auto"))
+
+	tests := []struct {
+		name   string
+		req    testRequest
+		ct     string
+		prefix string
+	}{
+		{"json", testRequest{target: "/openapi.json"}, fiber.MIMEApplicationJSON, `{"openapi":"3.0.1"`},
+		{"yaml", testRequest{target: "/openapi.yaml"}, yamlMediaType, "openapi: 3.0.1\n"},
+		{"auto default", testRequest{target: "/openapi"}, fiber.MIMEApplicationJSON, `{"openapi":"3.0.1"`},
+		{"auto query yaml", testRequest{target: "/openapi?format=yml"}, yamlMediaType, "openapi: 3.0.1\n"},
+		{"auto query json", testRequest{target: "/openapi?format=json", header: map[string]string{"Accept": "text/yaml"}}, fiber.MIMEApplicationJSON, `{"openapi":"3.0.1"`},
+		{"auto accept yaml", testRequest{target: "/openapi", header: map[string]string{"Accept": "application/yaml"}}, yamlMediaType, "openapi: 3.0.1\n"},
+		{"auto accept json", testRequest{target: "/openapi", header: map[string]string{"Accept": "application/json, text/yaml;q=0.5"}}, fiber.MIMEApplicationJSON, `{"openapi":"3.0.1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), tt.req)
+			if resp.StatusCode != 200 {
+				t.Fatalf("got status %d, want 200", resp.StatusCode)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != tt.ct {
+				t.Errorf("got Content-Type %q, want %q", ct, tt.ct)
+			}
+			if !strings.HasPrefix(body, tt.prefix) {
+				t.Errorf("got body %.40q, want prefix %q", body, tt.prefix)
+			}
+			if !strings.Contains(body, "listPets") || !strings.Contains(body, "Pets") {
+				t.Errorf("body does not document the operation and the info: %.200q", body)
+			}
+		})
+	}
+}
+
+func TestOptizz_OpenAPIInvalidContentType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New().OpenAPI(nil, "xml")
+}
+
+func TestOptizz_DocsUI(t *testing.T) {
+	f := New(WithDocsUI("docs", UIOptions{Title: "Pets API"}))
+
+	tests := []struct {
+		target string
+		status int
+		ct     string
+		body   string
+	}{
+		{"/docs", 200, fiber.MIMETextHTMLCharsetUTF8, "<title>Pets API</title>"},
+		{"/docs/index.html", 200, fiber.MIMETextHTMLCharsetUTF8, `url: "/docs/openapi.json"`},
+		{"/docs/swagger-ui-bundle.js", 200, "", "SwaggerUIBundle"},
+		{"/docs/openapi.json", 200, fiber.MIMEApplicationJSON, `"openapi":"3.0.1"`},
+		{"/docs/missing.js", 404, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: tt.target})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.ct != "" && resp.Header.Get(fiber.HeaderContentType) != tt.ct {
+				t.Errorf("got Content-Type %q, want %q", resp.Header.Get(fiber.HeaderContentType), tt.ct)
+			}
+			if !strings.Contains(body, tt.body) {
+				t.Errorf("body does not contain %q", tt.body)
+			}
+		})
+	}
+}
+
+func TestOptizz_DocsUIInvalidKind(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New().DocsUI("docs", UIOptions{Kind: SwaggerUI + 1})
+}
+
+func TestOptizz_OpenAPIConditional(t *testing.T) {
+	f := New()
+	f.Get("/pets", Handler(func(c *fiber.Ctx) error { return nil }, 200))
+	f.App().Get("/openapi.json", f.OpenAPI(nil, "json"))
+	f.App().Get("/openapi.yaml", f.OpenAPI(nil, "yaml"))
+
+	resp, body := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	etag, modified := resp.Header.Get(fiber.HeaderETag), resp.Header.Get(fiber.HeaderLastModified)
+	if etag == "" || modified == "" {
+		t.Fatalf("got ETag %q and Last-Modified %q, want both", etag, modified)
+	}
+	if _, again := serve(t, f.App(), testRequest{target: "/openapi.json"}); again != body {
+		t.Error("the cached specification differs")
+	}
+	yamlResp, _ := serve(t, f.App(), testRequest{target: "/openapi.yaml"})
+	if yamlResp.Header.Get(fiber.HeaderETag) == etag {
+		t.Error("the JSON and YAML specifications have the same ETag")
+	}
+
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": etag}, 304},
+		{"weak etag", map[string]string{"If-None-Match": "W/" + etag}, 304},
+		{"etag list", map[string]string{"If-None-Match": `"other", ` + etag}, 304},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, 304},
+		{"other etag", map[string]string{"If-None-Match": `"other"`}, 200},
+		{"etag precedence", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified}, 200},
+		{"not modified since", map[string]string{"If-Modified-Since": modified}, 304},
+		{"modified since", map[string]string{"If-Modified-Since": "Mon, 02 Jan 2006 15:04:05 GMT"}, 200},
+		{"invalid date", map[string]string{"If-Modified-Since": "yesterday"}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: "/openapi.json", header: tt.header})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == 304 && body != "" {
+				t.Errorf("got body %.40q, want none", body)
+			}
+		})
+	}
+
+	// Adding a route changes the specification.
+	f.Get("/owners", Handler(func(c *fiber.Ctx) error { return nil }, 200))
+	resp, body = serve(t, f.App(), testRequest{target: "/openapi.json", header: map[string]string{"If-None-Match": etag}})
+	if resp.StatusCode != 200 || resp.Header.Get(fiber.HeaderETag) == etag {
+		t.Fatalf("got status %d and ETag %q, want 200 and a new ETag", resp.StatusCode, resp.Header.Get(fiber.HeaderETag))
+	}
+	if !strings.Contains(body, "/owners") {
+		t.Error("the specification does not document the new route")
+	}
+}
+
+func TestNewFromApp_Options(t *testing.T) {
+	f := New(
+		WithInfo(&openapi.Info{Title: "Pets", Version: "2.0.0"}),
+		WithServers(&openapi.Server{URL: "https://api.example.com"}),
+		WithSpecConfig(nil),
+	)
+	api := f.Generator().API()
+	if api.Info.Title != "Pets" || api.Info.Version != "2.0.0" {
+		t.Errorf("got info %+v, want the info of the option", api.Info)
+	}
+	if len(api.Servers) != 1 || api.Servers[0].URL != "https://api.example.com" {
+		t.Errorf("got servers %+v, want the servers of the option", api.Servers)
+	}
+}
+
+func TestNewFromApp_GeneratorError(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(fmt.Sprint(r), "missing config") {
+			t.Errorf("got panic %v, want the error of the generator", r)
+		}
+	}()
+	New(func(o *options) { o.specConfig = nil })
+}
+
+func TestHandler_HTTPErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+	}{
+		{"not found", NotFound("pet not found"), 404, `{"message":"pet not found"}`},
+		{"conflict with code", Conflict("").WithCode("duplicate"), 409, `{"code":"duplicate","message":"Conflict"}`},
+		{"details", UnprocessableEntity("invalid pet").WithDetails(map[string]int{"age": -1}), 422, `{"message":"invalid pet","details":{"age":-1}}`},
+		{"no status", &HTTPError{Message: "boom"}, 500, `{"message":"boom"}`},
+		{"wrapped", fmt.Errorf("loading pet: %w", ServiceUnavailable("")), 503, `{"message":"Service Unavailable"}`},
+		{"plain error", fmt.Errorf("invalid pet"), 400, `{"error":"invalid pet"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New()
+			f.Get("/pets", Handler(func(c *fiber.Ctx) (*struct{}, error) { return nil, tt.err }, 200))
+
+			resp, body := serve(t, f.App(), testRequest{target: "/pets"})
+			if resp.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != fiber.MIMEApplicationJSON {
+				t.Errorf("got Content-Type %q, want %q", ct, fiber.MIMEApplicationJSON)
+			}
+			if body != tt.body {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := New()
+	f.Get("/pets/:id", Handler(func(c *fiber.Ctx) error { return nil }, 200, ID("getPet"), Errors(404, 409)))
+
+	op := f.Generator().API().Paths["/pets/{id}"].GET
+	for _, code := range []string{"404", "409"} {
+		r, ok := op.Responses[code]
+		if !ok || r.Response == nil {
+			t.Fatalf("response %s is not documented", code)
+		}
+		schema := r.Content[fiber.MIMEApplicationJSON].Schema
+		if schema == nil || schema.Reference == nil || schema.Reference.Ref != "#/components/schemas/OptizzHTTPError" {
+			t.Errorf("response %s does not use the shared error schema: %+v", code, schema)
+		}
+	}
+	if _, ok := f.Generator().API().Components.Schemas["OptizzHTTPError"]; !ok {
+		t.Error("the shared error schema is not documented")
+	}
+}
+
+type problemInput struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func TestProblemDetailsErrorHook(t *testing.T) {
+	f := New(WithErrorHook(ProblemDetailsErrorHook))
+	f.Post("/pets", Handler(func(c *fiber.Ctx, in *problemInput) (*struct{}, error) {
+		if in.Name == "rex" {
+			return nil, Conflict("rex already exists").WithCode("duplicate")
+		}
+		return nil, fmt.Errorf("cannot create %s: dial postgres://admin:secret@db", in.Name)
+	}, 201, ID("createPet"), Errors(409)))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		want   string
+	}{
+		{"http error", `{"name":"rex"}`, 409, `{"type":"about:blank","title":"Conflict","status":409,"detail":"rex already exists","instance":"/pets","code":"duplicate"}`},
+		{"plain error", `{"name":"tom"}`, 500, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal Server Error","instance":"/pets"}`},
+		{"malformed body", `{"name":`, 400, `{"type":"about:blank","title":"Bad Request","status":400,"detail":"binding error: error parsing request body: unexpected end of JSON input","instance":"/pets"}`},
+		{"validation error", `{}`, 400, `"detail":"the request input failed validation","instance":"/pets","errors":[{"field":"name","location":"body","tag":"required","message":"is required"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{
+				method: fiber.MethodPost,
+				target: "/pets",
+				header: map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationJSON},
+				body:   tt.body,
+			})
+			if resp.StatusCode != tt.status {
+				t.Errorf("got status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != ProblemMediaType {
+				t.Errorf("got Content-Type %q, want %q", ct, ProblemMediaType)
+			}
+			if !strings.HasSuffix(body, tt.want) {
+				t.Errorf("got body %s, want %s", body, tt.want)
+			}
+		})
+	}
+
+	op := f.Generator().API().Paths["/pets"].POST
+	for _, code := range []string{"409", "default"} {
+		r, ok := op.Responses[code]
+		if !ok || r.Response == nil {
+			t.Fatalf("response %s is not documented", code)
+		}
+		if _, ok := r.Content[ProblemMediaType]; !ok || len(r.Content) != 1 {
+			t.Errorf("response %s is not documented as a problem document", code)
+		}
+	}
+}
+
+type fieldErrorsOwner struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type fieldErrorsInput struct {
+	ID     int                `path:"id" validate:"min=1"`
+	Limit  int                `query:"limit" validate:"max=10"`
+	Token  string             `header:"X-Token" validate:"required"`
+	Name   string             `json:"name" validate:"required"`
+	Owners []fieldErrorsOwner `json:"owners" validate:"dive"`
+}
+
+func TestDefaultErrorHook_FieldErrors(t *testing.T) {
+	f := New()
+	f.Put("/pets/:id", Handler(func(c *fiber.Ctx, in *fieldErrorsInput) error { return nil }, 204))
+
+	tests := []struct {
+		name   string
+		req    testRequest
+		status int
+		errors string
+	}{
+		{
+			"valid",
+			testRequest{target: "/pets/1?limit=5", header: map[string]string{"X-Token": "t"}, body: `{"name":"rex"}`},
+			204, "",
+		},
+		{
+			"validation",
+			testRequest{target: "/pets/0?limit=20", body: `{"owners":[{"email":"x"}]}`},
+			400,
+			`[{"field":"id","location":"path","tag":"min","param":"1","message":"must be at least 1"},` +
+				`{"field":"limit","location":"query","tag":"max","param":"10","message":"must be at most 10"},` +
+				`{"field":"X-Token","location":"header","tag":"required","message":"is required"},` +
+				`{"field":"name","location":"body","tag":"required","message":"is required"},` +
+				`{"field":"owners[0].email","location":"body","tag":"email","message":"must be a valid email"}]`,
+		},
+		{
+			"binding",
+			testRequest{target: "/pets/1?limit=five", header: map[string]string{"X-Token": "t"}, body: `{"name":"rex"}`},
+			400,
+			`[{"field":"limit","location":"query","message":"strconv.ParseInt: parsing \"five\": invalid syntax"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.method = fiber.MethodPut
+			if tt.req.header == nil {
+				tt.req.header = make(map[string]string)
+			}
+			tt.req.header[fiber.HeaderContentType] = fiber.MIMEApplicationJSON
+			resp, body := serve(t, f.App(), tt.req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.errors != "" && !strings.HasSuffix(body, `"errors":`+tt.errors+`}`) {
+				t.Errorf("got body %s, want the errors %s", body, tt.errors)
+			}
+		})
+	}
+}
+
+type repeatedQueryInput struct {
+	IDs    []int     `query:"id"`
+	Tags   []string  `query:"tags" explode:"false"`
+	Sizes  [2]string `query:"size"`
+	Sort   string    `query:"sort"`
+	Fields []string  `query:"fields" default:"id,name"`
+}
+
+// echo returns a handler that renders its input,
+// for the binding tests.
+func echo[T any]() *OptizzHandler {
+	return H(func(c *fiber.Ctx, in *T) (*T, error) { return in, nil }, 200)
+}
+
+func TestBindQuery_Repeated(t *testing.T) {
+	f := New()
+	f.Get("/pets", echo[repeatedQueryInput]())
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"repeated", "?id=1&id=2&id=3", 200, `{"IDs":[1,2,3],"Tags":null,"Sizes":["",""],"Sort":"","Fields":["id","name"]}`},
+		{"empty values", "?id=1&id=&id=2", 200, `{"IDs":[1,2],"Tags":null,"Sizes":["",""],"Sort":"","Fields":["id","name"]}`},
+		{"comma-separated", "?tags=a,b", 200, `{"IDs":null,"Tags":["a","b"],"Sizes":["",""],"Sort":"","Fields":["id","name"]}`},
+		{"array", "?size=s&size=m", 200, `{"IDs":null,"Tags":null,"Sizes":["s","m"],"Sort":"","Fields":["id","name"]}`},
+		{"default override", "?fields=age", 200, `{"IDs":null,"Tags":null,"Sizes":["",""],"Sort":"","Fields":["age"]}`},
+		{"repeated comma-separated", "?tags=a&tags=b", 400, "repeating values not supported"},
+		{"repeated scalar", "?sort=a&sort=b", 400, "multiple values not supported"},
+		{"array length", "?size=s", 400, "parameter expect 2 values, got 1"},
+		{"invalid element", "?id=1&id=x", 400, `invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: "/pets" + tt.query})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.body || tt.status != 200 && !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+}
+
+type deepObjectOwner struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type deepObjectFilter struct {
+	Status string           `json:"status"`
+	Owner  *deepObjectOwner `json:"owner"`
+	IDs    []int            `json:"ids"`
+}
+
+type deepObjectPage struct {
+	Page struct {
+		Size int `json:"size"`
+	} `query:"page,style=deepObject"`
+}
+
+type deepObjectInput struct {
+	deepObjectPage
+	Filter deepObjectFilter  `query:"filter,style=deepObject"`
+	Labels map[string]string `query:"labels" style:"deepObject"`
+	Sort   string            `query:"sort,style=form"`
+	secret string
+}
+
+func TestBindQuery_DeepObject(t *testing.T) {
+	f := New()
+	f.Get("/pets", echo[deepObjectInput]())
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"empty", "", 200, `{"Page":{"size":0},"Filter":{"status":"","owner":null,"ids":null},"Labels":null,"Sort":""}`},
+		{
+			"nested",
+			"?filter[status]=open&filter[owner][id]=42&filter[ids]=1&filter[ids]=2&labels[env]=prod&page[size]=10&sort=name",
+			200,
+			`{"Page":{"size":10},"Filter":{"status":"open","owner":{"id":42,"name":""},"ids":[1,2]},"Labels":{"env":"prod"},"Sort":"name"}`,
+		},
+		{"unknown key", "?filter[color]=red", 200, `{"Page":{"size":0},"Filter":{"status":"","owner":null,"ids":null},"Labels":null,"Sort":""}`},
+		{"malformed key", "?filter[status", 400, "malformed deepObject key"},
+		{"invalid value", "?filter[owner][id]=x", 400, "invalid syntax"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: "/pets" + tt.query})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.body || tt.status != 200 && !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+
+	styles := make(map[string]string)
+	for _, p := range f.Generator().API().Paths["/pets"].GET.Parameters {
+		if p.Style == DeepObjectStyle && !p.Explode {
+			t.Errorf("parameter %s is not exploded", p.Name)
+		}
+		styles[p.Name] = p.Style
+	}
+	want := map[string]string{"page": DeepObjectStyle, "filter": DeepObjectStyle, "labels": DeepObjectStyle, "sort": ""}
+	if !reflect.DeepEqual(styles, want) {
+		t.Errorf("got styles %v, want %v", styles, want)
+	}
+}
+
+func TestParseTagKey_Style(t *testing.T) {
+	tests := []struct {
+		tag   string
+		name  string
+		style string
+		err   bool
+	}{
+		{"filter", "filter", "", false},
+		{"filter,style=deepObject", "filter", DeepObjectStyle, false},
+		{"filter, style=form,default=a", "filter", FormStyle, false},
+		{"filter,style=pipeDelimited", "", "", true},
+		{"filter,explode", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			name, _, _, style, err := parseTagKey(tt.tag)
+			if (err != nil) != tt.err {
+				t.Fatalf("got error %v, want error %t", err, tt.err)
+			}
+			if name != tt.name || style != tt.style {
+				t.Errorf("got name %q and style %q, want %q and %q", name, style, tt.name, tt.style)
+			}
+		})
+	}
+}
+
+type uploadInput struct {
+	Title       string                  `form:"title" json:"title" validate:"required"`
+	Document    *multipart.FileHeader   `form:"document" maxsize:"16B" accept:"application/pdf,image/*"`
+	Attachments []*multipart.FileHeader `form:"attachments"`
+}
+
+type uploadOutput struct {
+	Title       string   `json:"title"`
+	Document    string   `json:"document,omitempty"`
+	Attachments []string `json:"attachments,omitempty"`
+}
+
+// formPart is a part of a multipart form,
+// a file if it has a file name.
+type formPart struct {
+	name, filename, contentType, content string
+}
+
+// multipartBody returns the body of the multipart form
+// with the given parts, and its content type.
+func multipartBody(t *testing.T, parts ...formPart) (string, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		h := make(textproto.MIMEHeader)
+		if p.filename == "" {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, p.name))
+		} else {
+			h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, p.name, p.filename))
+			h.Set("Content-Type", p.contentType)
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pw.Write([]byte(p.content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String(), w.FormDataContentType()
+}
+
+func TestBindMultipart(t *testing.T) {
+	f := New()
+	f.Post("/documents", H(func(c *fiber.Ctx, in *uploadInput) (*uploadOutput, error) {
+		out := &uploadOutput{Title: in.Title}
+		if in.Document != nil {
+			out.Document = fmt.Sprintf("%s:%d", in.Document.Filename, in.Document.Size)
+		}
+		for _, fh := range in.Attachments {
+			out.Attachments = append(out.Attachments, fh.Filename)
+		}
+		return out, nil
+	}, 201))
+
+	tests := []struct {
+		name   string
+		parts  []formPart
+		status int
+		body   string
+	}{
+		{
+			"files",
+			[]formPart{
+				{name: "title", content: "report"},
+				{name: "document", filename: "report.pdf", contentType: "application/pdf", content: "%PDF-1.4"},
+				{name: "attachments", filename: "a.txt", contentType: "text/plain", content: "a"},
+				{name: "attachments", filename: "b.txt", contentType: "text/plain", content: "b"},
+			},
+			201, `{"title":"report","document":"report.pdf:8","attachments":["a.txt","b.txt"]}`,
+		},
+		{"fields only", []formPart{{name: "title", content: "report"}}, 201, `{"title":"report"}`},
+		{"wildcard media type", []formPart{
+			{name: "title", content: "scan"},
+			{name: "document", filename: "scan.png", contentType: "image/png", content: "png"},
+		}, 201, `{"title":"scan","document":"scan.png:3"}`},
+		{"missing field", []formPart{{name: "document", filename: "report.pdf", contentType: "application/pdf", content: "%PDF"}}, 400, `"field":"title","location":"body","tag":"required"`},
+		{"too large", []formPart{
+			{name: "title", content: "report"},
+			{name: "document", filename: "report.pdf", contentType: "application/pdf", content: "%PDF-1.4 too large file"},
+		}, 400, "exceeds the maximum size of 16B"},
+		{"media type", []formPart{
+			{name: "title", content: "report"},
+			{name: "document", filename: "report.txt", contentType: "text/plain", content: "report"},
+		}, 400, "unacceptable media type text/plain"},
+		{"multiple files", []formPart{
+			{name: "title", content: "report"},
+			{name: "document", filename: "a.pdf", contentType: "application/pdf", content: "a"},
+			{name: "document", filename: "b.pdf", contentType: "application/pdf", content: "b"},
+		}, 400, "multiple files not supported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, ct := multipartBody(t, tt.parts...)
+			resp, got := serve(t, f.App(), testRequest{
+				method: fiber.MethodPost,
+				target: "/documents",
+				header: map[string]string{fiber.HeaderContentType: ct},
+				body:   body,
+			})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, got)
+			}
+			if tt.status == 201 && got != tt.body || tt.status != 201 && !strings.Contains(got, tt.body) {
+				t.Errorf("got body %s, want %s", got, tt.body)
+			}
+		})
+	}
+
+	rb := f.Generator().API().Paths["/documents"].POST.RequestBody
+	content, ok := rb.Content[multipartMediaType]
+	if !ok || len(rb.Content) != 1 {
+		t.Fatalf("the request body is not documented as a multipart form: %v", rb.Content)
+	}
+	if enc := content.Encoding["document"]; enc == nil || enc.ContentType != "application/pdf, image/*" {
+		t.Errorf("got encoding %+v, want the accepted media types of the document", enc)
+	}
+}
+
+type profileForm struct {
+	Name   string                  `json:"displayName" form:"name"`
+	Photos []*multipart.FileHeader `json:"photos,omitempty" form:"photos"`
+}
+
+func TestBindMultipart_SharedSchema(t *testing.T) {
+	f := New()
+	f.Post("/profiles", echo[profileForm]())
+
+	op := f.Generator().API().Paths["/profiles"].POST
+	form, ok := op.RequestBody.Content[multipartMediaType]
+	if !ok || form.Schema.Schema == nil || form.Schema.Schema.Properties["name"] == nil {
+		t.Fatalf("the request body is not documented as a multipart form: %v", op.RequestBody.Content)
+	}
+	// The response references the component schema
+	// of the type, which keeps the JSON names.
+	resp := op.Responses["200"].Response.Content[JSONMediaType].Schema
+	if resp.Reference == nil {
+		t.Fatalf("the response does not reference the component schema")
+	}
+	schema := f.Generator().API().Components.Schemas[strings.TrimPrefix(resp.Reference.Ref, "#/components/schemas/")].Schema
+	if schema.Properties["displayName"] == nil || schema.Properties["name"] != nil {
+		t.Errorf("got component properties %v, want the JSON names", schema.Properties)
+	}
+	if schema.Properties["photos"] == form.Schema.Schema.Properties["photos"] {
+		t.Errorf("the files property of the form is shared with the component schema")
+	}
+}
+
+type signupInput struct {
+	FullName string `json:"fullName" form:"full_name" validate:"required"`
+	Age      int    `json:"age" form:"age"`
+}
+
+func TestBindForm(t *testing.T) {
+	f := New()
+	f.Post("/signups", echo[signupInput]())
+
+	tests := []struct {
+		name   string
+		ct     string
+		body   string
+		status int
+		resp   string
+	}{
+		{"json", JSONMediaType, `{"fullName":"Rex Dog","age":3}`, 200, `{"fullName":"Rex Dog","age":3}`},
+		{"urlencoded", fiber.MIMEApplicationForm, "full_name=Rex+Dog&age=3", 200, `{"fullName":"Rex Dog","age":3}`},
+		{"urlencoded missing", fiber.MIMEApplicationForm, "age=3", 400, `"field":"full_name"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, got := serve(t, f.App(), testRequest{
+				method: fiber.MethodPost,
+				target: "/signups",
+				header: map[string]string{fiber.HeaderContentType: tt.ct},
+				body:   tt.body,
+			})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, got)
+			}
+			if tt.status == 200 && got != tt.resp || tt.status != 200 && !strings.Contains(got, tt.resp) {
+				t.Errorf("got body %s, want %s", got, tt.resp)
+			}
+		})
+	}
+
+	// The types without files keep the JSON body, named
+	// after the JSON names, and document a URL-encoded
+	// form named after the form tags.
+	content := f.Generator().API().Paths["/signups"].POST.RequestBody.Content
+	if _, ok := content[multipartMediaType]; ok {
+		t.Errorf("got request body media types %v, want no multipart form", content)
+	}
+	form, ok := content[fiber.MIMEApplicationForm]
+	if !ok || form.Schema.Schema == nil || form.Schema.Schema.Properties["full_name"] == nil {
+		t.Fatalf("the request body is not documented as a URL-encoded form: %v", content)
+	}
+	json, ok := content[JSONMediaType]
+	if !ok {
+		t.Fatalf("the request body is not documented as JSON: %v", content)
+	}
+	schema := json.Schema.Schema
+	if ref := json.Schema.Reference; ref != nil {
+		schema = f.Generator().API().Components.Schemas[strings.TrimPrefix(ref.Ref, "#/components/schemas/")].Schema
+	}
+	if schema.Properties["fullName"] == nil || schema.Properties["full_name"] != nil {
+		t.Errorf("got JSON properties %v, want the JSON names", schema.Properties)
+	}
+}
+
+type sessionInput struct {
+	Name string `json:"name"`
+	SID  string `cookie:"sid" validate:"required"`
+	Page int    `query:"page"`
+}
+
+func TestBindCookies_NotFromBody(t *testing.T) {
+	f := New()
+	f.Post("/sessions", echo[sessionInput]())
+
+	tests := []struct {
+		name   string
+		cookie string
+		status int
+		body   string
+	}{
+		{"spoofed", "", 400, `"field":"sid","location":"cookie","tag":"required"`},
+		{"cookie", "sid=abc", 200, `{"name":"a","SID":"abc","Page":7}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{fiber.HeaderContentType: JSONMediaType}
+			if tt.cookie != "" {
+				header["Cookie"] = tt.cookie
+			}
+			resp, body := serve(t, f.App(), testRequest{
+				method: fiber.MethodPost,
+				target: "/sessions",
+				header: header,
+				body:   `{"name":"a","sid":"evil","page":7}`,
+			})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.body || tt.status != 200 && !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+}
+
+type bodyOrQueryInput struct {
+	ID string `json:"id" query:"id"`
+}
+
+func TestBindParams_BodyFallback(t *testing.T) {
+	// The fields bound from the query and from the body
+	// keep the body value if the parameter is absent.
+	f := New()
+	f.Post("/items", echo[bodyOrQueryInput]())
+
+	for target, want := range map[string]string{
+		"/items":          `{"id":"body"}`,
+		"/items?id=query": `{"id":"query"}`,
+	} {
+		resp, body := serve(t, f.App(), testRequest{
+			method: fiber.MethodPost,
+			target: target,
+			header: map[string]string{fiber.HeaderContentType: JSONMediaType},
+			body:   `{"id":"body"}`,
+		})
+		if resp.StatusCode != 200 || body != want {
+			t.Errorf("got status %d and body %s for %s, want 200 and %s", resp.StatusCode, body, target, want)
+		}
+	}
+}
+
+type cookieInput struct {
+	SessionID string `cookie:"sid" validate:"required"`
+	Lang      string `cookie:"lang" enum:"en,fr" default:"en"`
+	Visits    int    `cookie:"visits"`
+}
+
+func TestBindCookie(t *testing.T) {
+	f := New()
+	f.Get("/session", echo[cookieInput]())
+
+	tests := []struct {
+		name   string
+		cookie string
+		status int
+		body   string
+	}{
+		{"all", "sid=abc; lang=fr; visits=3", 200, `{"SessionID":"abc","Lang":"fr","Visits":3}`},
+		{"default", "sid=abc", 200, `{"SessionID":"abc","Lang":"en","Visits":0}`},
+		{"missing", "lang=fr", 400, `"field":"sid","location":"cookie","tag":"required"`},
+		{"enum", "sid=abc; lang=de", 400, `"field":"lang","location":"cookie","message":"parameter has not an acceptable value, enum=[en fr]"`},
+		{"invalid", "sid=abc; visits=many", 400, `"field":"visits","location":"cookie"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: "/session", header: map[string]string{"Cookie": tt.cookie}})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.body || tt.status != 200 && !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+
+	op := f.Generator().API().Paths["/session"].GET
+	if op.RequestBody != nil {
+		t.Errorf("got request body %+v, want none", op.RequestBody)
+	}
+	required := make(map[string]bool)
+	for _, p := range op.Parameters {
+		if p.In == CookieTag {
+			required[p.Name] = p.Required
+		}
+	}
+	if want := map[string]bool{"sid": true, "lang": false, "visits": false}; !reflect.DeepEqual(required, want) {
+		t.Errorf("got cookie parameters %v, want %v", required, want)
+	}
+}
+
+func TestRemoveBodyProperty_SharedSchema(t *testing.T) {
+	shared := &openapi.SchemaOrRef{Schema: &openapi.Schema{
+		Type:     "object",
+		Required: []string{"sid"},
+		Properties: map[string]*openapi.SchemaOrRef{
+			"sid": {Schema: &openapi.Schema{Type: "string"}},
+		},
+	}}
+	body := func() *openapi.RequestBody {
+		return &openapi.RequestBody{Content: map[string]*openapi.MediaType{
+			JSONMediaType: {Schema: &openapi.SchemaOrRef{Reference: &openapi.Reference{Ref: "#/components/schemas/Session"}}},
+		}}
+	}
+	get, put := &openapi.Operation{RequestBody: body()}, &openapi.Operation{RequestBody: body()}
+	api := &openapi.OpenAPI{
+		Paths:      openapi.Paths{"/session": &openapi.PathItem{GET: get, PUT: put}},
+		Components: &openapi.Components{Schemas: map[string]*openapi.SchemaOrRef{"Session": shared}},
+	}
+
+	refs := &schemaRefs{api: api}
+	removeBodyProperty(api, get, "sid", refs)
+	if get.RequestBody != nil {
+		t.Errorf("got request body %+v, want none", get.RequestBody)
+	}
+	if api.Components.Schemas["Session"] != shared || len(shared.Properties) != 1 || len(shared.Required) != 1 {
+		t.Fatalf("the shared schema was changed: %+v", api.Components.Schemas["Session"])
+	}
+
+	// The last reference is removed with the schema.
+	removeBodyProperty(api, put, "sid", refs)
+	if _, ok := api.Components.Schemas["Session"]; ok || put.RequestBody != nil {
+		t.Error("the unreferenced schema was not removed")
+	}
+}
+
+func TestSchemaRefs(t *testing.T) {
+	ref := func(name string) *openapi.SchemaOrRef {
+		return &openapi.SchemaOrRef{Reference: &openapi.Reference{Ref: "#/components/schemas/" + name}}
+	}
+	op := &openapi.Operation{
+		RequestBody: &openapi.RequestBody{Content: map[string]*openapi.MediaType{JSONMediaType: {Schema: ref("Pet")}}},
+		Responses: openapi.Responses{"200": {Response: &openapi.Response{Content: map[string]*openapi.MediaTypeOrRef{
+			JSONMediaType: {MediaType: &openapi.MediaType{Schema: ref("PetList")}},
+		}}}},
+	}
+	api := &openapi.OpenAPI{
+		Paths: openapi.Paths{"/pets": &openapi.PathItem{POST: op}},
+		Components: &openapi.Components{Schemas: map[string]*openapi.SchemaOrRef{
+			"Pet":     {Schema: &openapi.Schema{Type: "object"}},
+			"PetList": {Schema: &openapi.Schema{Type: "object", Properties: map[string]*openapi.SchemaOrRef{"items": {Schema: &openapi.Schema{Type: "array", Items: ref("Owner")}}}}},
+			"Owner":   {Schema: &openapi.Schema{Type: "object", AdditionalProperties: ref("Owner")}},
+		}},
+	}
+	refs := &schemaRefs{api: api}
+	for name, shared := range map[string]bool{"Pet": false, "PetList": false, "Owner": true} {
+		if refs.shared(name) != shared {
+			t.Errorf("got shared %t for %s, want %t", !shared, name, shared)
+		}
+	}
+}
+
+type negotiationPet struct {
+	XMLName struct{} `json:"-" xml:"pet" codec:"-"`
+	Name    string   `json:"name" xml:"name" codec:"name"`
+	Age     int      `json:"age" xml:"age" codec:"age"`
+}
+
+func TestNegotiation(t *testing.T) {
+	f := New()
+	f.Post("/pets", H(func(c *fiber.Ctx, in *negotiationPet) (*negotiationPet, error) { return in, nil }, 201))
+	f.Post("/json-pets", H(func(c *fiber.Ctx, in *negotiationPet) (*negotiationPet, error) {
+		return in, nil
+	}, 201, Consumes(JSONMediaType), Produces(JSONMediaType)))
+	f.Post("/codec-pets", H(func(c *fiber.Ctx, in *negotiationPet) (*negotiationPet, error) {
+		return in, nil
+	}, 201, Consumes(JSONMediaType, XMLMediaType, MessagePackMediaType),
+		Produces(JSONMediaType, XMLMediaType, MessagePackMediaType)))
+
+	msgpack, _ := GetCodec(MessagePackMediaType)
+	pet, _ := msgpack.Marshal(&negotiationPet{Name: "rex", Age: 3})
+
+	tests := []struct {
+		name   string
+		target string
+		ct     string
+		accept string
+		body   string
+		status int
+		respCT string
+		resp   string
+	}{
+		{"json", "/pets", JSONMediaType, "", `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"undeclared xml", "/pets", XMLMediaType, "", `<pet><name>rex</name><age>3</age></pet>`, 415, JSONMediaType, `{"message":"unsupported media type \"application/xml\""}`},
+		{"undeclared msgpack", "/pets", JSONMediaType, MessagePackMediaType, `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"browser", "/pets", JSONMediaType, "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"any", "/pets", JSONMediaType, "*/*", `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"text", "/pets", JSONMediaType, "text/plain", `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"xml", "/codec-pets", XMLMediaType, XMLMediaType, `<pet><name>rex</name><age>3</age></pet>`, 201, XMLMediaType, `<pet><name>rex</name><age>3</age></pet>`},
+		{"xml to json", "/codec-pets", "application/xml; charset=utf-8", "application/json", `<pet><name>rex</name><age>3</age></pet>`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"msgpack", "/codec-pets", MessagePackMediaType, MessagePackMediaType, string(pet), 201, MessagePackMediaType, string(pet)},
+		{"preference", "/codec-pets", JSONMediaType, "application/xml;q=0.5, application/json", `{"name":"rex","age":3}`, 201, JSONMediaType, `{"name":"rex","age":3}`},
+		{"excluded", "/codec-pets", JSONMediaType, "application/json;q=0, application/*;q=0.8", `{"name":"rex","age":3}`, 201, XMLMediaType, `<pet><name>rex</name><age>3</age></pet>`},
+		{"none acceptable", "/codec-pets", JSONMediaType, "text/html", `{"name":"rex","age":3}`, 406, JSONMediaType, `{"message":"Not Acceptable"}`},
+		{"unsupported body", "/pets", "text/csv", "", "rex,3", 415, JSONMediaType, `{"message":"unsupported media type \"text/csv\""}`},
+		{"not consumed", "/json-pets", XMLMediaType, "", `<pet><name>rex</name></pet>`, 415, JSONMediaType, `{"message":"unsupported media type \"application/xml\""}`},
+		{"not acceptable", "/json-pets", JSONMediaType, XMLMediaType, `{"name":"rex","age":3}`, 406, JSONMediaType, `{"message":"Not Acceptable"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{fiber.HeaderContentType: tt.ct}
+			if tt.accept != "" {
+				header[fiber.HeaderAccept] = tt.accept
+			}
+			resp, body := serve(t, f.App(), testRequest{method: fiber.MethodPost, target: tt.target, header: header, body: tt.body})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != tt.respCT {
+				t.Errorf("got Content-Type %q, want %q", ct, tt.respCT)
+			}
+			if body != tt.resp {
+				t.Errorf("got body %q, want %q", body, tt.resp)
+			}
+		})
+	}
+
+	// The undeclared operations are documented with JSON.
+	if content := f.Generator().API().Paths["/pets"].POST.RequestBody.Content; len(content) != 1 || content[JSONMediaType] == nil {
+		t.Errorf("got request body media types %v, want JSON", content)
+	}
+	if content := f.Generator().API().Paths["/pets"].POST.Responses["201"].Content; len(content) != 1 || content[JSONMediaType] == nil {
+		t.Errorf("got response media types %v, want JSON", content)
+	}
+	if content := f.Generator().API().Paths["/codec-pets"].POST.Responses["201"].Content; len(content) != 3 || content[MessagePackMediaType] == nil {
+		t.Errorf("got response media types %v, want the produced ones", content)
+	}
+	op := f.Generator().API().Paths["/json-pets"].POST
+	if _, ok := op.RequestBody.Content[JSONMediaType]; !ok || len(op.RequestBody.Content) != 1 {
+		t.Errorf("got request body media types %v, want the consumed ones", op.RequestBody.Content)
+	}
+}
+
+func TestNegotiation_RenderHook(t *testing.T) {
+	// The custom render hooks read the negotiated media type,
+	// and the requests that accept none are still rejected.
+	f := New(WithRenderHook(func(c *fiber.Ctx, status int, payload interface{}) {
+		c.Status(status).SendString(ResponseMediaType(c))
+	}))
+	f.Get("/pets", HOut(func(c *fiber.Ctx) (*negotiationPet, error) { return &negotiationPet{Name: "rex"}, nil }, 200))
+	f.Get("/codec-pets", HOut(func(c *fiber.Ctx) (*negotiationPet, error) {
+		return &negotiationPet{Name: "rex"}, nil
+	}, 200, Produces(JSONMediaType, CBORMediaType)))
+
+	tests := []struct {
+		target string
+		accept string
+		status int
+		body   string
+	}{
+		{"/pets", "", 200, JSONMediaType},
+		{"/pets", CBORMediaType, 200, JSONMediaType},
+		{"/codec-pets", "", 200, JSONMediaType},
+		{"/codec-pets", CBORMediaType, 200, CBORMediaType},
+		{"/codec-pets", "text/plain", 406, JSONMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target+"/"+tt.accept, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: tt.target, header: map[string]string{fiber.HeaderAccept: tt.accept}})
+			if resp.StatusCode != tt.status || body != tt.body {
+				t.Errorf("got status %d and body %q, want %d and %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+		})
+	}
+}
+
+func TestOperationOptions(t *testing.T) {
+	// The options defined by the users have
+	// the signature of the builtin ones.
+	custom := OperationOption(func(o *openapi.OperationInfo) { o.Description = "custom" })
+	var builtin func(*openapi.OperationInfo) = Summary("List pets")
+
+	info, ext := newOperationInfo([]OperationOption{
+		builtin,
+		custom,
+		Consumes(XMLMediaType),
+		StrictJSON(true),
+		Tags("pets"),
+		AddTags("store"),
+		Security(SecurityRequirement{"key": nil}),
+	})
+	if info.Summary != "List pets" || info.Description != "custom" {
+		t.Errorf("got summary %q and description %q, want those of the options", info.Summary, info.Description)
+	}
+	if !reflect.DeepEqual(ext.consumes, []string{XMLMediaType}) || ext.strictJSON == nil || !*ext.strictJSON ||
+		!ext.setTags || !reflect.DeepEqual(ext.tags, []string{"pets"}) || !reflect.DeepEqual(ext.addTags, []string{"store"}) ||
+		!reflect.DeepEqual(ext.security, []SecurityRequirement{{"key": {}}}) {
+		t.Errorf("got extended options %+v, want those of the options", ext)
+	}
+
+	// The options of a handler are its own.
+	h1 := Handler(func(c *fiber.Ctx) error { return nil }, 200, Produces(XMLMediaType))
+	h2 := Handler(func(c *fiber.Ctx) error { return nil }, 200)
+	if !reflect.DeepEqual(h1.ext.produces, []string{XMLMediaType}) || h2.ext.produces != nil {
+		t.Errorf("got produced media types %v and %v, want [%s] and none", h1.ext.produces, h2.ext.produces, XMLMediaType)
+	}
+	if len(opExts) != 0 {
+		t.Errorf("got %d recorded extended options, want none", len(opExts))
+	}
+	// The extended options applied outside of
+	// a handler are not silently discarded.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("got no panic applying an extended option to the info of a handler")
+		}
+	}()
+	Produces(XMLMediaType)(h2.OperationInfo)
+}
 
+func TestNegotiate(t *testing.T) {
+	offers := []string{JSONMediaType, XMLMediaType, CBORMediaType}
+	tests := []struct {
+		accept string
+		want   string
+		ok     bool
+	}{
+		{"", JSONMediaType, true},
+		{"*/*", JSONMediaType, true},
+		{"application/cbor", CBORMediaType, true},
+		{"application/xml;q=0.5, application/json", JSONMediaType, true},
+		{"application/xml, application/json;q=0.9", XMLMediaType, true},
+		{"application/*;q=0.2, application/cbor;q=0.4", CBORMediaType, true},
+		{"*/*;q=0.1, application/json;q=0", XMLMediaType, true},
+		{"APPLICATION/XML", XMLMediaType, true},
+		{"text/html", JSONMediaType, false},
+		{"application/json;q=0", JSONMediaType, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			if got, ok := negotiate(tt.accept, offers); got != tt.want || ok != tt.ok {
+				t.Errorf("got %s, %t, want %s, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+type strictItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type strictOrder struct {
+	ID    string       `path:"id"`
+	Note  string       `json:"note"`
+	Items []strictItem `json:"items"`
+}
+
+func TestStrictJSON(t *testing.T) {
+	f := New(WithStrictJSON(true))
+	f.Put("/orders/:id", H(func(c *fiber.Ctx, in *strictOrder) (*strictOrder, error) { return in, nil }, 200, ID("putOrder")))
+	f.Post("/orders/:id", H(func(c *fiber.Ctx, in *strictOrder) (*strictOrder, error) { return in, nil }, 200, ID("postOrder"), StrictJSON(false)))
+	f.App().Get("/openapi.json", f.OpenAPI(nil, "json"))
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+		resp   string
+	}{
+		{"valid", fiber.MethodPut, `{"note":"asap","items":[{"sku":"a","qty":1}]}`, 200, `{"ID":"1","note":"asap","items":[{"sku":"a","qty":1}]}`},
+		{"unknown field", fiber.MethodPut, `{"note":"asap","color":"red"}`, 400, `"errors":[{"field":"color","location":"body","message":"unknown field"}]`},
+		{"nested unknown field", fiber.MethodPut, `{"items":[{"sku":"a"},{"sku":"b","size":2}]}`, 400, `"errors":[{"field":"items[1].size","location":"body","message":"unknown field"}]`},
+		{"trailing data", fiber.MethodPut, `{"note":"asap"} {}`, 400, "unexpected data after the top-level value of the body"},
+		{"type mismatch", fiber.MethodPut, `{"items":[{"qty":"one"}]}`, 400, `"errors":[{"field":"items[0].qty","location":"body","message":"cannot decode a JSON string into a value of type int"}]`},
+		{"lenient", fiber.MethodPost, `{"note":"asap","color":"red"}`, 200, `{"ID":"1","note":"asap","items":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{
+				method: tt.method,
+				target: "/orders/1",
+				header: map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationJSON},
+				body:   tt.body,
+			})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.resp || tt.status != 200 && !strings.Contains(body, tt.resp) {
+				t.Errorf("got body %s, want %s", body, tt.resp)
+			}
+		})
+	}
+
+	_, spec := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	if !strings.Contains(spec, `"PutOrderInput":{"additionalProperties":false`) {
+		t.Error("the strict request body does not forbid additional properties")
+	}
+	if strings.Contains(spec, `"PostOrderInput":{"additionalProperties":false`) {
+		t.Error("the lenient request body forbids additional properties")
+	}
+	// The nested schema is shared with the lenient request
+	// body and the responses, so the strict request body
+	// refers to a closed copy of its own.
+	if !strings.Contains(spec, `"OptizzStrictItemStrict":{"additionalProperties":false`) ||
+		!strings.Contains(spec, `"PutOrderInput":{"additionalProperties":false,"properties":{"items":{"items":{"$ref":"#/components/schemas/OptizzStrictItemStrict"}`) {
+		t.Errorf("the nested schema of the strict request body allows additional properties: %s", spec)
+	}
+	if strings.Contains(spec, `"OptizzStrictItem":{"additionalProperties":false`) ||
+		!strings.Contains(spec, `"PostOrderInput":{"properties":{"items":{"items":{"$ref":"#/components/schemas/OptizzStrictItem"}`) ||
+		!strings.Contains(spec, `"OptizzStrictOrder":{"properties":{"ID":{"type":"string"},"items":{"items":{"$ref":"#/components/schemas/OptizzStrictItem"}`) {
+		t.Errorf("the shared nested schema is closed for the lenient request body and the responses: %s", spec)
+	}
+}
+
+type PlanPaging struct {
+	Page  int `query:"page" default:"1"`
+	Limit int `query:"limit" validate:"max=100"`
+}
+
+type planInput struct {
+	*PlanPaging
+	ID      string    `path:"id"`
+	Version *string   `header:"X-Version"`
+	Since   time.Time `query:"since"`
+	Kind    string    `query:"kind" enum:"cat,dog"`
+}
+
+type malformedPlanInput struct {
+	Sort string `query:"sort,ascending"`
+}
+
+func TestCloseInlineSchemas(t *testing.T) {
+	var schema map[string]interface{}
+	err := json.Unmarshal([]byte(`{"type":"object","properties":{
+		"meta":{"type":"object","properties":{"a":{"type":"string"}}},
+		"tags":{"type":"array","items":{"type":"object","properties":{"b":{"type":"string"}}}},
+		"labels":{"type":"object","additionalProperties":{"type":"string"}},
+		"owner":{"$ref":"#/components/schemas/Owner"}
+	}}`), &schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closeInlineSchemas(schema, 0)
+
+	b, _ := json.Marshal(schema)
+	for _, want := range []string{
+		`"meta":{"additionalProperties":false`,
+		`"items":{"additionalProperties":false`,
+		`"labels":{"additionalProperties":{"type":"string"}`,
+		`"owner":{"$ref":"#/components/schemas/Owner"}`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("got schema %s, want %s", b, want)
+		}
+	}
+}
+
+func TestBindingPlan(t *testing.T) {
+	if planOf(reflect.TypeOf(planInput{})) != planOf(reflect.TypeOf(&planInput{})) {
+		t.Error("the binding plan is not compiled once per type")
+	}
+	f := New()
+	f.Get("/pets/:id", H(func(c *fiber.Ctx, in *planInput) (*string, error) {
+		version := "none"
+		if in.Version != nil {
+			version = *in.Version
+		}
+		s := fmt.Sprintf("%s page=%d limit=%d version=%s since=%s kind=%s",
+			in.ID, in.Page, in.Limit, version, in.Since.Format(time.RFC3339), in.Kind)
+		return &s, nil
+	}, 200))
+
+	tests := []struct {
+		name   string
+		req    testRequest
+		status int
+		body   string
+	}{
+		{"defaults", testRequest{target: "/pets/1"}, 200, `"1 page=1 limit=0 version= since=0001-01-01T00:00:00Z kind="`},
+		{"all", testRequest{
+			target: "/pets/2?page=3&limit=50&since=2021-01-02T15:04:05Z&kind=cat",
+			header: map[string]string{"X-Version": "7"},
+		}, 200, `"2 page=3 limit=50 version=7 since=2021-01-02T15:04:05Z kind=cat"`},
+		{"embedded validation", testRequest{target: "/pets/1?limit=500"}, 400, `"field":"limit","location":"query","tag":"max"`},
+		{"enum", testRequest{target: "/pets/1?kind=bird"}, 400, `parameter has not an acceptable value, enum=[cat dog]`},
+		{"text unmarshaler", testRequest{target: "/pets/1?since=yesterday"}, 400, `"field":"since","location":"query"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, f.App(), tt.req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 200 && body != tt.body || tt.status != 200 && !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+		})
+	}
+
+	// The errors of the tags are reported when binding.
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.SetRequestURI("/malformed?sort=name")
+	ctx := f.App().AcquireCtx(fctx)
+	defer f.App().ReleaseCtx(ctx)
+
+	err := DefaultBindQueryHook(ctx, reflect.ValueOf(&malformedPlanInput{}))
+	if be, ok := err.(BindError); !ok || !strings.Contains(be.Error(), "unknown option 'ascending'") {
+		t.Errorf("got error %v, want a BindError for the malformed tag", err)
+	}
+}
+
+type responseInner struct {
+	Value int `json:"value" validate:"min=1"`
+}
+
+type responseOuter struct {
+	Name   string            `json:"name" validate:"required"`
+	Kind   string            `json:"kind" enum:"cat,dog"`
+	Age    *int              `json:"age"`
+	Count  int               `json:"count" validate:"min=0"`
+	Inner  *responseInner    `json:"inner"`
+	Tags   []string          `json:"tags"`
+	Labels map[string]string `json:"labels"`
+	Parent *responseInner    `json:"parent,omitempty"`
+	Items  []string          `json:"items,omitempty"`
+}
+
+func TestSchemaValidator_ZeroBounds(t *testing.T) {
+	// The bounds of 0 are not enforced by the generated schemas,
+	// since openapi.Schema cannot tell them from the absent ones.
+	sv := &schemaValidator{}
+	sv.validate(&openapi.SchemaOrRef{Schema: &openapi.Schema{Type: "integer", Minimum: 0}}, nil, json.Number("-5"), "count")
+	if len(sv.errs) != 0 {
+		t.Errorf("got errors %v, want none", sv.errs)
+	}
+	sv.validate(&openapi.SchemaOrRef{Schema: &openapi.Schema{Type: "integer", Minimum: 1}}, nil, json.Number("-5"), "count")
+	if len(sv.errs) != 1 || sv.errs[0].Tag != "minimum" {
+		t.Errorf("got errors %v, want a minimum error", sv.errs)
+	}
+}
+
+func TestResponseValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		out    *responseOuter
+		status int
+		body   string
+		log    string
+	}{
+		{"nil optional scalar", &responseOuter{Name: "a", Kind: "cat", Inner: &responseInner{Value: 1}, Tags: []string{}, Labels: map[string]string{}}, 200, `{"name":"a","kind":"cat","age":null,"count":0,"inner":{"value":1},"tags":[],"labels":{}}`, ""},
+		{"nil slice", &responseOuter{Name: "a", Kind: "cat", Inner: &responseInner{Value: 1}, Labels: map[string]string{}}, 500, `"code":"invalid_response"`, "does not match its schema: tags must not be null\n"},
+		{"nil map", &responseOuter{Name: "a", Kind: "cat", Inner: &responseInner{Value: 1}, Tags: []string{}}, 500, `"code":"invalid_response"`, "does not match its schema: labels must not be null\n"},
+		{"nil struct", &responseOuter{Name: "a", Kind: "cat", Tags: []string{}, Labels: map[string]string{}}, 500, `"code":"invalid_response"`, "does not match its schema: inner must not be null\n"},
+		{"zero bound", &responseOuter{Name: "a", Kind: "cat", Count: -5, Inner: &responseInner{Value: 1}, Tags: []string{}, Labels: map[string]string{}}, 500, `"code":"invalid_response"`, "count must be at least 0"},
+		{"validate tags", &responseOuter{Name: "a", Kind: "cat", Inner: &responseInner{}}, 500, `"code":"invalid_response"`, "does not match its schema: inner.value must be at least 1;"},
+		{"schema", &responseOuter{Name: "a", Kind: "bird", Inner: &responseInner{Value: 1}, Tags: []string{}, Labels: map[string]string{}}, 500, `"code":"invalid_response"`, "does not match its schema: kind must be one of [cat dog]\n"},
+		{"required", &responseOuter{}, 500, `"code":"invalid_response"`, "name is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			f := New(WithResponseValidation(ResponseValidationFail), WithLogger(log.New(&logs, "", 0)))
+			f.Get("/pets", HOut(func(c *fiber.Ctx) (*responseOuter, error) { return tt.out, nil }, 200))
+
+			resp, body := serve(t, f.App(), testRequest{target: "/pets"})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != fiber.MIMEApplicationJSON {
+				t.Errorf("got Content-Type %q, want %q", ct, fiber.MIMEApplicationJSON)
+			}
+			if !strings.Contains(body, tt.body) {
+				t.Errorf("got body %s, want %s", body, tt.body)
+			}
+			// The violations are logged, and
+			// not sent to the client.
+			if strings.Contains(body, "details") {
+				t.Errorf("got body %s, want no details", body)
+			}
+			if !strings.Contains(logs.String(), tt.log) || tt.log == "" && logs.Len() != 0 {
+				t.Errorf("got logs %q, want %q", logs.String(), tt.log)
+			}
+		})
+	}
+
+	// The invalid responses are rendered as
+	// usual when they are only logged.
+	var logs bytes.Buffer
+	f := New(WithResponseValidation(ResponseValidationLog), WithLogger(log.New(&logs, "", 0)))
+	f.Get("/pets", HOut(func(c *fiber.Ctx) (*responseOuter, error) { return &responseOuter{Kind: "bird"}, nil }, 200))
+	if resp, _ := serve(t, f.App(), testRequest{target: "/pets"}); resp.StatusCode != 200 {
+		t.Errorf("got status %d, want 200", resp.StatusCode)
+	}
+	if !strings.Contains(logs.String(), "kind must be one of [cat dog]") {
+		t.Errorf("got logs %q, want the violations", logs.String())
+	}
+}
+
+const validationSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "pets", "version": "1.0.0"},
+  "paths": {
+    "/pets/{id}": {
+      "get": {
+        "operationId": "getPet",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "maximum": 10}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      }
+    },
+    "/owners/{id}": {
+      "parameters": [
+        {"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}
+      ],
+      "get": {
+        "operationId": "getOwner",
+        "parameters": [{"$ref": "#/components/parameters/limit"}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "limit": {"name": "limit", "in": "query", "required": true, "schema": {"type": "integer", "maximum": 10}}
+    }
+  }
+}`
+
+func TestValidateRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		config fiber.Config
+		target string
+		status int
+	}{
+		{"valid", fiber.Config{}, "/pets/1?limit=5", 200},
+		{"invalid path parameter", fiber.Config{}, "/pets/a", 400},
+		{"invalid query parameter", fiber.Config{}, "/pets/1?limit=50", 400},
+		{"case insensitive", fiber.Config{}, "/PETS/a", 400},
+		{"trailing slash", fiber.Config{}, "/pets/a/", 400},
+		{"case sensitive", fiber.Config{CaseSensitive: true}, "/PETS/a", 404},
+		{"strict routing", fiber.Config{StrictRouting: true}, "/pets/a/", 404},
+		{"path item parameters", fiber.Config{}, "/owners/1?limit=5", 200},
+		{"invalid path item parameter", fiber.Config{}, "/owners/abc?limit=5", 400},
+		{"missing reference parameter", fiber.Config{}, "/owners/1", 400},
+		{"invalid reference parameter", fiber.Config{}, "/owners/1?limit=999", 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(tt.config)
+			f, err := FromSpec(app, []byte(validationSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Use(f.ValidateRequests())
+			app.Get("/pets/:id", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+			app.Get("/owners/:id", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			resp, body := serve(t, app, testRequest{target: tt.target})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+			if tt.status == 400 && !strings.Contains(body, `the request does not match the specification`) {
+				t.Errorf("got body %s", body)
+			}
+		})
+	}
+}
+
+const explodeSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "pets", "version": "1.0.0"},
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "listPets",
+        "parameters": [
+          {"name": "ids", "in": "query", "schema": {"type": "array", "items": {"type": "integer"}}},
+          {"name": "tags", "in": "query", "explode": false, "schema": {"type": "array", "items": {"type": "integer"}}}
+        ],
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  }
+}`
+
+type explodeInput struct {
+	IDs  []int `query:"ids"`
+	Tags []int `query:"tags" explode:"false"`
+}
+
+func TestValidateRequests_Explode(t *testing.T) {
+	// The array query parameters are exploded unless
+	// written or bound otherwise, like the binder does.
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"ids=1&ids=2", 200},
+		{"ids=1,2", 400},
+		{"tags=1,2", 200},
+		{"tags=1,a", 400},
+	}
+	app := fiber.New()
+	written, err := FromSpec(app, []byte(explodeSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Use(written.ValidateRequests())
+	app.Get("/pets", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+	generated := New()
+	generated.App().Use(generated.ValidateRequests())
+	generated.Get("/pets", HIn(func(c *fiber.Ctx, in *explodeInput) error { return nil }, 200))
+
+	for _, tt := range tests {
+		for name, app := range map[string]*fiber.App{"written": app, "generated": generated.App()} {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				resp, body := serve(t, app, testRequest{target: "/pets?" + tt.query})
+				if resp.StatusCode != tt.status {
+					t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+				}
+				if tt.status == 400 && !strings.Contains(body, "the request does not match the specification") {
+					t.Errorf("got body %s, want a validation error", body)
+				}
+			})
+		}
+	}
+}
+
+type problemPetInput struct {
+	ID int `path:"id"`
+}
+
+func TestValidateRequests_GroupHooks(t *testing.T) {
+	// The errors are rendered with the
+	// hooks of the group of the route.
+	f := New()
+	f.App().Use(f.ValidateRequests())
+	admin := f.Group("/admin", "admin", "")
+	admin.SetHooks(Hooks{Error: ProblemDetailsErrorHook})
+	admin.Get("/pets/:id", HIn(func(c *fiber.Ctx, in *problemPetInput) error { return nil }, 200))
+	f.Get("/pets/:id", HIn(func(c *fiber.Ctx, in *problemPetInput) error { return nil }, 200))
+
+	tests := []struct {
+		target string
+		ct     string
+	}{
+		{"/admin/pets/a", ProblemMediaType},
+		{"/pets/a", JSONMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: tt.target})
+			if resp.StatusCode != 400 || !strings.Contains(body, "the request does not match the specification") {
+				t.Fatalf("got status %d and body %s, want a validation error", resp.StatusCode, body)
+			}
+			if ct := resp.Header.Get(fiber.HeaderContentType); ct != tt.ct {
+				t.Errorf("got Content-Type %q, want %q", ct, tt.ct)
+			}
+		})
+	}
+}
+
+const bodySpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "pets", "version": "1.0.0"},
+  "paths": {
+    "/p": {
+      "post": {
+        "operationId": "createP",
+        "requestBody": {"content": {"application/json": {"schema": {
+          "type": "object",
+          "properties": {"name": {"type": "string", "minLength": 3}}
+        }}}},
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  }
+}`
+
+func TestValidateRequests_Body(t *testing.T) {
+	tests := []struct {
+		name   string
+		ct     string
+		body   string
+		status int
+	}{
+		{"valid", JSONMediaType, `{"name":"rex"}`, 200},
+		{"invalid", JSONMediaType, `{"name":"x"}`, 400},
+		{"undocumented text", "text/plain", `{"name":"x"}`, 415},
+		{"undocumented msgpack", MessagePackMediaType, `{"name":"x"}`, 415},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			f, err := FromSpec(app, []byte(bodySpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Use(f.ValidateRequests())
+			app.Post("/p", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			header := map[string]string{fiber.HeaderContentType: tt.ct}
+			resp, body := serve(t, app, testRequest{method: fiber.MethodPost, target: "/p", header: header, body: tt.body})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+		})
+	}
+}
+
+const boundsSpec = `
+openapi: 3.0.3
+info:
+  title: pets
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            minimum: 0
+        - $ref: '#/components/parameters/ratio'
+      responses:
+        "200":
+          description: OK
+    post:
+      operationId: createPet
+      requestBody:
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/Pet'
+      responses:
+        "200":
+          description: OK
+components:
+  parameters:
+    ratio:
+      name: ratio
+      in: query
+      schema:
+        type: number
+        maximum: 0.5
+        exclusiveMaximum: true
+  schemas:
+    Pet:
+      allOf:
+        - type: object
+          properties:
+            weight:
+              type: number
+              minimum: 0
+              multipleOf: 0.25
+`
+
+func TestValidateRequests_WrittenBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"valid", fiber.MethodGet, "/pets?limit=0&ratio=0.25", "", 200},
+		{"minimum of 0", fiber.MethodGet, "/pets?limit=-5", "", 400},
+		{"fractional maximum", fiber.MethodGet, "/pets?ratio=0.9", "", 400},
+		{"exclusive maximum", fiber.MethodGet, "/pets?ratio=0.5", "", 400},
+		{"valid body", fiber.MethodPost, "/pets", `{"weight":1.75}`, 200},
+		{"body minimum of 0", fiber.MethodPost, "/pets", `{"weight":-1}`, 400},
+		{"body fractional multiple", fiber.MethodPost, "/pets", `{"weight":1.3}`, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			f, err := FromSpec(app, []byte(boundsSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Use(f.ValidateRequests())
+			app.All("/pets", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			header := map[string]string{fiber.HeaderContentType: JSONMediaType}
+			resp, body := serve(t, app, testRequest{method: tt.method, target: tt.target, header: header, body: tt.body})
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+		})
+	}
+}
+
+type boundsPet struct {
+	Weight float64 `json:"weight"`
+}
+
+func TestImplement_WrittenResponseBounds(t *testing.T) {
+	var logs bytes.Buffer
+	f, err := FromSpec(fiber.New(), []byte(strings.Replace(boundsSpec, `        "200":
+          description: OK
+components:`, `        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/Pet'
+components:`, 1)), WithResponseValidation(ResponseValidationFail), WithLogger(log.New(&logs, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Implement("createPet", H(func(c *fiber.Ctx, in *boundsPet) (*boundsPet, error) {
+		return &boundsPet{Weight: -in.Weight}, nil
+	}, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := map[string]string{fiber.HeaderContentType: JSONMediaType}
+	resp, body := serve(t, f.App(), testRequest{method: fiber.MethodPost, target: "/pets", header: header, body: `{"weight":1}`})
+	if resp.StatusCode != 500 || !strings.Contains(logs.String(), "must be greater than or equal to 0") {
+		t.Errorf("got status %d, body %s and logs %q, want a 500 for the minimum of 0", resp.StatusCode, body, logs.String())
+	}
+}
+
+const contractSpec = `
+openapi: 3.0.3
+info:
+  title: pets
+  version: 1.0.0
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          required: true
+          schema:
+            type: integer
+      responses:
+        "200":
+          description: OK
+    post:
+      operationId: createPet
+      requestBody:
+        $ref: '#/components/requestBodies/NewPet'
+      responses:
+        "201":
+          description: Created
+components:
+  requestBodies:
+    NewPet:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Pet'
+  schemas:
+    Named:
+      type: object
+      properties:
+        name:
+          type: string
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/Named'
+        - type: object
+          properties:
+            weight:
+              type: number
+              minimum: 0.5
+            tag:
+              oneOf:
+                - type: string
+                - type: integer
+`
+
+type contractPage struct {
+	Limit int `query:"limit" validate:"required"`
+}
+
+type contractOptionalPage struct {
+	Limit int `query:"limit"`
+}
+
+func TestFromSpec_ServedAsWritten(t *testing.T) {
+	f, err := FromSpec(fiber.New(), []byte(contractSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Get("/openapi.json", nil, f.OpenAPI(nil, "json"))
+
+	_, body := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	for _, want := range []string{
+		`"allOf":[{"$ref":"#/components/schemas/Named"}`,
+		`"minimum":0.5`,
+		`"oneOf":[{"type":"string"},{"type":"integer"}]`,
+		`"requestBody":{"$ref":"#/components/requestBodies/NewPet"}`,
+		`"requestBodies":{"NewPet":`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got specification %s, want %s", body, want)
+		}
+	}
+}
+
+const filesSpec = `
+openapi: 3.0.3
+info:
+  title: files
+  version: 1.0.0
+paths:
+  %s:
+    get:
+      operationId: getFile
+      parameters:
+        - {name: name, in: path, required: true, schema: {type: string}}
+        - {name: ext, in: path, required: true, schema: {type: string}}
+      responses:
+        "200":
+          description: The file.
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  name: {type: string}
+                  ext: {type: string}
+`
+
+type fileParams struct {
+	Name string `path:"name" json:"name"`
+	Ext  string `path:"ext" json:"ext"`
+}
+
+func TestFromSpec_PathTemplates(t *testing.T) {
+	if got := fiberPath("/files/{name}.{ext}/v{version}"); got != "/files/:name.:ext/v:version" {
+		t.Errorf("got Fiber path %s, want /files/:name.:ext/v:version", got)
+	}
+	f, err := FromSpec(fiber.New(), []byte(fmt.Sprintf(filesSpec, "/files/{name}.{ext}")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Implement("getFile", echo[fileParams]()); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := serve(t, f.App(), testRequest{target: "/files/report.pdf"}); resp.StatusCode != 200 || body != `{"name":"report","ext":"pdf"}` {
+		t.Errorf("got status %d and body %s, want 200 and the parameters", resp.StatusCode, body)
+	}
+
+	// The templates that Fiber cannot express are rejected.
+	for _, path := range []string{"/files/{name}_{ext}", "/files/{name}{ext}", "/files/{file-name}.{ext}", "/files/{name.{ext}"} {
+		if _, err := FromSpec(fiber.New(), []byte(fmt.Sprintf(filesSpec, path))); err == nil || !strings.Contains(err.Error(), "invalid path template") {
+			t.Errorf("got error %v for %s, want an invalid path template", err, path)
+		}
+	}
+}
+
+func TestImplement_RequiredParameter(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler *OptizzHandler
+		err     string
+	}{
+		{"required by the validate tag", HIn(func(c *fiber.Ctx, in *contractPage) error { return nil }, 200), ""},
+		{"optional", HIn(func(c *fiber.Ctx, in *contractOptionalPage) error { return nil }, 200), `field Limit binds the required query parameter "limit", but is optional`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := FromSpec(fiber.New(), []byte(contractSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.Implement("listPets", tt.handler)
+			if tt.err == "" && err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+const propertiesSpec = `
+openapi: 3.0.3
+info:
+  title: items
+  version: 1.0.0
+paths:
+  /open:
+    post:
+      operationId: createOpen
+      requestBody:
+        $ref: '#/components/requestBodies/Open'
+      responses:
+        "204":
+          description: No Content
+  /loose:
+    post:
+      operationId: createLoose
+      requestBody:
+        $ref: '#/components/requestBodies/Loose'
+      responses:
+        "204":
+          description: No Content
+  /closed:
+    post:
+      operationId: createClosed
+      requestBody:
+        $ref: '#/components/requestBodies/Closed'
+      responses:
+        "204":
+          description: No Content
+components:
+  requestBodies:
+    Open:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Open'
+    Loose:
+      $ref: '#/components/requestBodies/LooseBody'
+    LooseBody:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Loose'
+    Closed:
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/Closed'
+  schemas:
+    Open:
+      type: object
+      properties:
+        name:
+          type: string
+    Loose:
+      type: object
+      additionalProperties: true
+      properties:
+        name:
+          type: string
+    Closed:
+      type: object
+      additionalProperties: false
+      properties:
+        name:
+          type: string
+`
+
+type propertiesExtra struct {
+	Name  string `json:"name"`
+	Extra string `json:"extra"`
+}
+
+type propertiesMismatch struct {
+	Name int `json:"name"`
+}
+
+func TestImplement_AdditionalProperties(t *testing.T) {
+	tests := []struct {
+		operation string
+		handler   func() *OptizzHandler
+		err       string
+	}{
+		{"createOpen", func() *OptizzHandler {
+			return HIn(func(c *fiber.Ctx, in *propertiesExtra) error { return nil }, 204)
+		}, ""},
+		{"createLoose", func() *OptizzHandler {
+			return HIn(func(c *fiber.Ctx, in *propertiesExtra) error { return nil }, 204)
+		}, ""},
+		{"createClosed", func() *OptizzHandler {
+			return HIn(func(c *fiber.Ctx, in *propertiesExtra) error { return nil }, 204)
+		}, "request body: field Extra of type optizz.propertiesExtra is not documented"},
+		{"createOpen", func() *OptizzHandler {
+			return HIn(func(c *fiber.Ctx, in *propertiesMismatch) error { return nil }, 204)
+		}, "request body: field Name of type int does not match the schema of type string"},
+		{"createLoose", func() *OptizzHandler {
+			return HIn(func(c *fiber.Ctx, in *propertiesMismatch) error { return nil }, 204)
+		}, "request body: field Name of type int does not match the schema of type string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			f, err := FromSpec(fiber.New(), []byte(propertiesSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = f.Implement(tt.operation, tt.handler())
+			if tt.err == "" && err != nil {
+				t.Fatalf("got error %s", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
+
+type authUser struct {
+	Name string `json:"name"`
+}
+
+func newAuthApp(t *testing.T, useMiddleware bool) *Optizz {
+	t.Helper()
+
+	f := New()
+	f.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+	f.SecurityScheme("bearer", BearerScheme("JWT"))
+	authenticate := f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) {
+			if key != "secret" {
+				return nil, Forbidden("invalid key")
+			}
+			return &authUser{Name: "key"}, nil
+		}),
+		"bearer": BearerAuthenticator(func(c *fiber.Ctx, token string, scopes []string) (interface{}, error) {
+			if token != "token" {
+				return nil, errors.New("invalid token")
+			}
+			return &authUser{Name: "bearer"}, nil
+		}),
+	})
+	if useMiddleware {
+		f.App().Use(authenticate)
+	}
+	me := HOut(func(c *fiber.Ctx) (*authUser, error) {
+		user, _ := Principal[*authUser](c)
+		return user, nil
+	}, 200)
+
+	admin := f.Group("/admin", "admin", "Administration")
+	admin.SetSecurity(SecurityRequirement{"key": nil}, SecurityRequirement{"bearer": nil})
+	admin.Get("/secret", me)
+	admin.Get("/health", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200, Public()))
+	return f
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    testRequest
+		status int
+		body   string
+	}{
+		{"missing credentials", testRequest{target: "/admin/secret"}, 401, `"unauthorized"`},
+		{"case variant", testRequest{target: "/ADMIN/secret"}, 401, `"unauthorized"`},
+		{"trailing slash", testRequest{target: "/admin/secret/"}, 401, `"unauthorized"`},
+		{"api key", testRequest{target: "/admin/secret", header: map[string]string{"X-API-Key": "secret"}}, 200, `{"name":"key"}`},
+		{"bearer", testRequest{target: "/Admin/Secret", header: map[string]string{"Authorization": "Bearer token"}}, 200, `{"name":"bearer"}`},
+		{"http error", testRequest{target: "/admin/secret", header: map[string]string{"X-API-Key": "wrong"}}, 403, `invalid key`},
+		{"invalid token", testRequest{target: "/admin/secret", header: map[string]string{"Authorization": "Bearer wrong"}}, 401, `"unauthorized"`},
+		{"public", testRequest{target: "/admin/health"}, 200, `{"name":""}`},
+	}
+	for _, middleware := range []bool{true, false} {
+		for _, tt := range tests {
+			t.Run(fmt.Sprintf("%s/middleware=%t", tt.name, middleware), func(t *testing.T) {
+				f := newAuthApp(t, middleware)
+
+				resp, body := serve(t, f.App(), tt.req)
+				if resp.StatusCode != tt.status {
+					t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+				}
+				if !strings.Contains(body, tt.body) {
+					t.Errorf("got body %s, want %s", body, tt.body)
+				}
+				if tt.status == 401 {
+					if h := resp.Header.Get(fiber.HeaderWWWAuthenticate); h != "Bearer" {
+						t.Errorf("got WWW-Authenticate %q, want Bearer", h)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAuthenticate_FailClosed(t *testing.T) {
+	var logs bytes.Buffer
+	newApp := func() *Optizz {
+		f := New(WithLogger(log.New(&logs, "", 0)))
+		f.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+		f.SecurityScheme("bearer", BearerScheme("JWT"))
+		f.Get("/secret", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200, Security(SecurityRequirement{"key": nil})))
+		f.Get("/health", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200))
+		return f
+	}
+	header := map[string]string{"X-API-Key": "secret"}
+	f := newApp()
+	f.Authenticate(map[string]Authenticator{
+		"bearer": BearerAuthenticator(func(c *fiber.Ctx, token string, scopes []string) (interface{}, error) { return &authUser{}, nil }),
+	})
+	if resp, body := serve(t, f.App(), testRequest{target: "/secret", header: header}); resp.StatusCode != 500 || !strings.Contains(body, `"invalid_security"`) {
+		t.Errorf("got status %d without the authenticator of the scheme, want 500: %s", resp.StatusCode, body)
+	}
+	if resp, body := serve(t, f.App(), testRequest{target: "/health"}); resp.StatusCode != 200 {
+		t.Errorf("got status %d for a public route, want 200: %s", resp.StatusCode, body)
+	}
+	if resp, body := serve(t, f.App(), testRequest{target: "/secret", header: header}); resp.Header.Get(fiber.HeaderWWWAuthenticate) != "" || strings.Contains(body, "X-API-Key") {
+		t.Errorf("got the details of a misconfigured requirement: %s", body)
+	}
+	if !strings.Contains(logs.String(), `no authenticator for the security scheme "key"`) {
+		t.Errorf("the misconfigured requirement is not logged: %q", logs.String())
+	}
+
+	f = newApp()
+	f.Get("/unknown", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200, Security(SecurityRequirement{"missing": nil})))
+	f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) { return key, nil }),
+	})
+	if resp, body := serve(t, f.App(), testRequest{target: "/unknown", header: header}); resp.StatusCode != 500 {
+		t.Errorf("got status %d for an unknown scheme, want 500: %s", resp.StatusCode, body)
+	}
+}
+
+func TestAuthenticate_NotEnforced(t *testing.T) {
+	f := New()
+	f.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+	f.Get("/secret", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200, Security(SecurityRequirement{"key": nil})))
+	admin := f.Group("/admin", "admin", "Administration")
+	admin.SetSecurity(SecurityRequirement{"key": nil})
+	admin.Get("/users", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200))
+	f.App().Get("/openapi.json", f.OpenAPI(nil, "json"))
+
+	// The requirements are documented, but the app
+	// authenticates the requests on its own.
+	for _, target := range []string{"/secret", "/admin/users"} {
+		if resp, body := serve(t, f.App(), testRequest{target: target}); resp.StatusCode != 200 {
+			t.Errorf("got status %d for %s without Authenticate, want 200: %s", resp.StatusCode, target, body)
+		}
+	}
+	if _, spec := serve(t, f.App(), testRequest{target: "/openapi.json"}); !strings.Contains(spec, `"security":[{"key":[]}]`) {
+		t.Errorf("the security requirements are not documented: %s", spec)
+	}
+
+	f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) { return key, nil }),
+	})
+	if resp, body := serve(t, f.App(), testRequest{target: "/secret"}); resp.StatusCode != 401 {
+		t.Errorf("got status %d after Authenticate, want 401: %s", resp.StatusCode, body)
+	}
+}
+
+func TestAuthenticate_SecurityAfterRegistration(t *testing.T) {
+	f := New()
+	f.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+	f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) { return &authUser{Name: "key"}, nil }),
+	})
+	f.Get("/me", echo[authUser]())
+	admin := f.Group("/admin", "admin", "Administration")
+	admin.Group("/users", "users", "Users").Get("/", echo[authUser]())
+	f.App().Get("/openapi.json", f.OpenAPI(nil, "json"))
+
+	for _, target := range []string{"/me", "/admin/users/"} {
+		if resp, body := serve(t, f.App(), testRequest{target: target}); resp.StatusCode != 200 {
+			t.Fatalf("got status %d for %s before SetSecurity: %s", resp.StatusCode, target, body)
+		}
+	}
+	f.SetSecurity(SecurityRequirement{"key": nil})
+	admin.SetSecurity(SecurityRequirement{"key": {"admin"}})
+
+	for _, target := range []string{"/me", "/admin/users/"} {
+		if resp, body := serve(t, f.App(), testRequest{target: target}); resp.StatusCode != 401 {
+			t.Errorf("got status %d for %s, want 401: %s", resp.StatusCode, target, body)
+		}
+		if resp, body := serve(t, f.App(), testRequest{target: target, header: map[string]string{"X-API-Key": "secret"}}); resp.StatusCode != 200 {
+			t.Errorf("got status %d for %s with a key, want 200: %s", resp.StatusCode, target, body)
+		}
+	}
+	route, err := f.RouteByOperationID(f.Routes()[1].GetOperationID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := route.GetSecurity(); !reflect.DeepEqual(got, []SecurityRequirement{{"key": {"admin"}}}) {
+		t.Errorf("got route security %v", got)
+	}
+	_, body := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Security []SecurityRequirement `json:"security"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Paths["/me"]["get"].Security; !reflect.DeepEqual(got, []SecurityRequirement{{"key": {}}}) {
+		t.Errorf("got documented security %v for /me", got)
+	}
+	if got := doc.Paths["/admin/users/"]["get"].Security; !reflect.DeepEqual(got, []SecurityRequirement{{"key": {"admin"}}}) {
+		t.Errorf("got documented security %v for /admin/users/", got)
+	}
+}
+
+const authSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "pets", "version": "1.0.0"},
+  "security": [{"key": []}],
+  "paths": {
+    "/pets": {
+      "get": {"operationId": "listPets", "responses": {"200": {"description": "OK"}}}
+    },
+    "/pets/{id}": {
+      "get": {"operationId": "getPet", "responses": {"200": {"description": "OK"}}}
+    },
+    "/health": {
+      "get": {"operationId": "health", "security": [], "responses": {"200": {"description": "OK"}}}
+    }
+  },
+  "components": {
+    "securitySchemes": {"key": {"type": "apiKey", "in": "header", "name": "X-API-Key"}}
+  }
+}`
+
+func TestAuthenticate_FromSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		req    testRequest
+		status int
+	}{
+		{"implemented", testRequest{target: "/PETS"}, 401},
+		{"implemented with key", testRequest{target: "/pets", header: map[string]string{"X-API-Key": "secret"}}, 200},
+		{"app route", testRequest{target: "/Pets/1"}, 401},
+		{"app route with key", testRequest{target: "/pets/1", header: map[string]string{"X-API-Key": "secret"}}, 200},
+		{"public", testRequest{target: "/health"}, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			f, err := FromSpec(app, []byte(authSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			app.Use(f.Authenticate(map[string]Authenticator{
+				"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) {
+					if key != "secret" {
+						return nil, errors.New("invalid key")
+					}
+					return key, nil
+				}),
+			}))
+			if err := f.Implement("listPets", HIn(func(c *fiber.Ctx, in *struct{}) error { return nil }, 200)); err != nil {
+				t.Fatal(err)
+			}
+			app.Get("/pets/:id", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+			app.Get("/owners/:id", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+			app.Get("/health", func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+			resp, body := serve(t, app, tt.req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("got status %d, want %d: %s", resp.StatusCode, tt.status, body)
+			}
+		})
+	}
+}
+
+func TestImplement_SecurityAndTags(t *testing.T) {
+	app := fiber.New()
+	f, err := FromSpec(app, []byte(authSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.Use(f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) { return key, nil }),
+	}))
+	handler := func(infos ...OperationOption) *OptizzHandler {
+		return HIn(func(c *fiber.Ctx, in *struct{}) error { return nil }, 200, infos...)
+	}
+	if err := f.Implement("getPet", handler(Public(), Tags("pets"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Implement("listPets", handler(AddTags("pets", "search"))); err != nil {
+		t.Fatal(err)
+	}
+	app.Get("/openapi.json", f.OpenAPI(nil, "json"))
+
+	if resp, body := serve(t, app, testRequest{target: "/pets/1"}); resp.StatusCode != 200 {
+		t.Errorf("got status %d, want 200 for the public operation: %s", resp.StatusCode, body)
+	}
+	if resp, body := serve(t, app, testRequest{target: "/pets"}); resp.StatusCode != 401 {
+		t.Errorf("got status %d, want 401 for the secured operation: %s", resp.StatusCode, body)
+	}
+	_, spec := serve(t, app, testRequest{target: "/openapi.json"})
+	for _, want := range []string{
+		`"get":{"operationId":"getPet","responses":{"200":{"description":"OK"}},"security":[],"tags":["pets"]}`,
+		`"get":{"operationId":"listPets","responses":{"200":{"description":"OK"}},"security":[{"key":[]}],"tags":["pets","search"]}`,
+		`"tags":[{"name":"pets"},{"name":"search"}]`,
+	} {
+		if !strings.Contains(spec, want) {
+			t.Errorf("got specification %s, want %s", spec, want)
+		}
+	}
+}
+
+func TestAuthenticate_Mount(t *testing.T) {
+	other := New()
+	other.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+	other.SetSecurity(SecurityRequirement{"key": nil})
+	other.Get("/invoices", HOut(func(c *fiber.Ctx) (*authUser, error) { return &authUser{}, nil }, 200))
+
+	f := New()
+	if err := f.Mount("/billing", other); err != nil {
+		t.Fatal(err)
+	}
+	f.Authenticate(map[string]Authenticator{
+		"key": APIKeyAuthenticator(func(c *fiber.Ctx, key string) (interface{}, error) { return key, nil }),
+	})
+	if resp, body := serve(t, f.App(), testRequest{target: "/BILLING/invoices"}); resp.StatusCode != 401 {
+		t.Fatalf("got status %d, want 401: %s", resp.StatusCode, body)
+	}
+	if resp, body := serve(t, f.App(), testRequest{target: "/billing/invoices", header: map[string]string{"X-API-Key": "k"}}); resp.StatusCode != 200 {
+		t.Fatalf("got status %d, want 200: %s", resp.StatusCode, body)
+	}
+}
+
+func TestSecurity_Specification(t *testing.T) {
+	f := New()
+	f.SecurityScheme("key", APIKeyScheme(HeaderTag, "X-API-Key"))
+	f.SecurityScheme("oauth", OAuth2Scheme(&OAuthFlows{
+		ClientCredentials: &OAuthFlow{TokenURL: "https://example.com/token", Scopes: map[string]string{"read": "Read"}},
+	}))
+	f.Get("/open", echo[struct{}]())
+
+	pets := f.Group("/pets", "pets", "Pets")
+	pets.SetSecurity(SecurityRequirement{"key": nil})
+	pets.Get("/list", echo[struct{}]())
+	pets.Get("/public", H(func(c *fiber.Ctx, in *struct{}) (*struct{}, error) { return in, nil }, 200, Public()))
+	pets.Group("/admin", "admin", "Admin").Get("/stats", echo[struct{}]())
+	pets.Get("/scoped", H(func(c *fiber.Ctx, in *struct{}) (*struct{}, error) { return in, nil }, 200,
+		Security(SecurityRequirement{"oauth": {"read"}}, SecurityRequirement{"key": nil})))
+	f.Get("/openapi.json", nil, f.OpenAPI(nil, "json"))
+
+	_, body := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	var doc struct {
+		Components struct {
+			SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+		} `json:"components"`
+		Paths map[string]map[string]struct {
+			Security []SecurityRequirement `json:"security"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if s := doc.Components.SecuritySchemes["key"]; s == nil || s.Type != APIKeySecurity || s.In != HeaderTag || s.Name != "X-API-Key" {
+		t.Errorf("got security scheme %+v", s)
+	}
+	if s := doc.Components.SecuritySchemes["oauth"]; s == nil || s.Flows == nil || s.Flows.ClientCredentials == nil {
+		t.Errorf("got security scheme %+v", s)
+	}
+	tests := []struct {
+		path string
+		want []SecurityRequirement
+	}{
+		{"/open", nil},
+		{"/pets/list", []SecurityRequirement{{"key": {}}}},
+		{"/pets/public", []SecurityRequirement{}},
+		{"/pets/admin/stats", []SecurityRequirement{{"key": {}}}},
+		{"/pets/scoped", []SecurityRequirement{{"oauth": {"read"}}, {"key": {}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			op, ok := doc.Paths[tt.path]["get"]
+			if !ok {
+				t.Fatalf("operation GET %s is not documented", tt.path)
+			}
+			if !reflect.DeepEqual(op.Security, tt.want) {
+				t.Errorf("got security %v, want %v", op.Security, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddServer(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		vars map[string]*openapi.ServerVariable
+		err  string
+	}{
+		{"static", "https://api.example.com", nil, ""},
+		{"variables", "https://{region}.api.example.com/{version}", map[string]*openapi.ServerVariable{
+			"region":  {Default: "eu", Enum: []string{"eu", "us"}},
+			"version": {Default: "v1"},
+		}, ""},
+		{"empty URL", "", nil, "empty server URL"},
+		{"unterminated variable", "https://{region.api.example.com", nil, "unterminated variable"},
+		{"undescribed variable", "https://{region}.api.example.com", nil, `variable "region" is not described`},
+		{"no default", "https://{region}.api.example.com", map[string]*openapi.ServerVariable{"region": {}}, `variable "region" has no default value`},
+		{"default not in enum", "https://{region}.api.example.com", map[string]*openapi.ServerVariable{
+			"region": {Default: "ap", Enum: []string{"eu", "us"}},
+		}, `the default value of variable "region" is not in its enum`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New()
+			err := f.AddServer(tt.url, "server", tt.vars)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %s", err, tt.err)
+				}
+				if len(f.Generator().API().Servers) != 0 {
+					t.Error("the invalid server was added")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if servers := f.Generator().API().Servers; len(servers) != 1 || servers[0].URL != tt.url {
+				t.Errorf("got servers %+v", servers)
+			}
+		})
+	}
+}
+
+func TestRouterGroup_AddServer(t *testing.T) {
+	f := New()
+	if err := f.AddServer("https://api.example.com", "API", nil); err != nil {
+		t.Fatal(err)
+	}
+	f.Get("/pets", echo[struct{}]())
+
+	files := f.Group("/files", "files", "Files")
+	if err := files.AddServer("https://files.example.com", "Files", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := files.AddServer("{bad", "Files", nil); err == nil {
+		t.Error("got no error for an invalid group server")
+	}
+	files.Get("/list", echo[struct{}]())
+	files.Group("/images", "images", "Images").Get("/list", echo[struct{}]())
+
+	// The groups of the same path have their own servers.
+	uploads := f.Group("/files", "uploads", "Uploads")
+	if err := uploads.AddServer("https://uploads.example.com", "Uploads", nil); err != nil {
+		t.Fatal(err)
+	}
+	uploads.Post("/list", echo[struct{}]())
+
+	// The servers apply to the operations registered before.
+	reports := f.Group("/reports", "reports", "Reports")
+	reports.Get("/daily", echo[struct{}]())
+	reports.Post("/daily", echo[struct{}]())
+	if err := reports.AddServer("https://reports.example.com", "Reports", nil); err != nil {
+		t.Fatal(err)
+	}
+	urls := func(servers []*openapi.Server) []string {
+		var urls []string
+		for _, s := range servers {
+			urls = append(urls, s.URL)
+		}
+		return urls
+	}
+	paths := f.Generator().API().Paths
+	tests := []struct {
+		path   string
+		method string
+		onPath []string
+		onOp   []string
+	}{
+		{"/pets", fiber.MethodGet, nil, nil},
+		{"/files/list", fiber.MethodGet, nil, []string{"https://files.example.com"}},
+		{"/files/list", fiber.MethodPost, nil, []string{"https://uploads.example.com"}},
+		{"/files/images/list", fiber.MethodGet, []string{"https://files.example.com"}, nil},
+		{"/reports/daily", fiber.MethodGet, []string{"https://reports.example.com"}, nil},
+		{"/reports/daily", fiber.MethodPost, []string{"https://reports.example.com"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			item := paths[tt.path]
+			if item == nil || pathOperation(item, tt.method) == nil {
+				t.Fatalf("operation %s %s is not documented", tt.method, tt.path)
+			}
+			if got := urls(item.Servers); !reflect.DeepEqual(got, tt.onPath) {
+				t.Errorf("got servers %v on the path, want %v", got, tt.onPath)
+			}
+			if got := urls(pathOperation(item, tt.method).Servers); !reflect.DeepEqual(got, tt.onOp) {
+				t.Errorf("got servers %v on the operation, want %v", got, tt.onOp)
+			}
+		})
+	}
+
+	// The specification served before is invalidated.
+	f.Get("/openapi.json", nil, f.OpenAPI(nil, "json"))
+	serve(t, f.App(), testRequest{target: "/openapi.json"})
+	if err := reports.AddServer("https://reports-eu.example.com", "Reports EU", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, body := serve(t, f.App(), testRequest{target: "/openapi.json"}); !strings.Contains(body, "https://reports-eu.example.com") {
+		t.Errorf("got specification %s, want the server added afterwards", body)
+	}
+}
+
+// mountInstance returns an instance that documents the
+// operation of the given ID and the Invoice schema.
+func mountInstance(path, id string) *Optizz {
+	type Invoice struct {
+		Amount int `json:"amount"`
+	}
+	f := New()
+	f.Get(path, HOut(func(c *fiber.Ctx) (*Invoice, error) { return &Invoice{Amount: 1}, nil }, 200, ID(id)))
+	return f
+}
+
+// otherInvoice documents an Invoice schema with
+// a different definition than mountInstance.
+func otherInvoice(f *Optizz) {
+	type Invoice struct {
+		Total string `json:"total"`
+	}
+	f.Get("/invoices", HOut(func(c *fiber.Ctx) (*Invoice, error) { return &Invoice{}, nil }, 200, ID("listInvoices")))
+}
+
+func TestMount(t *testing.T) {
+	f := New()
+	f.Get("/pets", echo[struct{}]())
+	other := mountInstance("/invoices", "listBilling")
+	if err := f.Mount("/billing/", other); err != nil {
+		t.Fatal(err)
+	}
+	api := f.Generator().API()
+	if _, ok := api.Paths["/billing/invoices"]; !ok {
+		t.Errorf("got paths %v, want /billing/invoices", api.Paths)
+	}
+	if _, ok := api.Components.Schemas["OptizzInvoice"]; !ok {
+		t.Error("the OptizzInvoice schema is not merged")
+	}
+	if r, err := f.RouteByOperationID("listBilling"); err != nil || r.GetPath() != "/billing/invoices" {
+		t.Errorf("got route %v, %v", r, err)
+	}
+	resp, body := serve(t, f.App(), testRequest{target: "/billing/invoices"})
+	if resp.StatusCode != 200 || body != `{"amount":1}` {
+		t.Errorf("got status %d and body %s", resp.StatusCode, body)
+	}
+	// The instance itself is left untouched.
+	if _, ok := other.Generator().API().Paths["/billing/invoices"]; ok {
+		t.Error("the specification of the mounted instance was modified")
+	}
+}
+
+func TestMount_Conflicts(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(f *Optizz)
+		other *Optizz
+		err   string
+	}{
+		{"invalid instance", func(f *Optizz) {}, nil, "invalid instance to mount"},
+		{"path", func(f *Optizz) { f.Get("/billing/invoices", echo[struct{}]()) }, mountInstance("/invoices", "listBilling"), "path /billing/invoices is already documented"},
+		{"operation ID", func(f *Optizz) {
+			f.Get("/pets", HIn(func(c *fiber.Ctx, in *struct{}) error { return nil }, 200, ID("listBilling")))
+		}, mountInstance("/invoices", "listBilling"), "operation ID listBilling is already documented"},
+		{"schema", otherInvoice, mountInstance("/list", "listBilling"), "schema OptizzInvoice is already documented with a different definition"},
+		{"security scheme", func(f *Optizz) { f.SecurityScheme("key", BasicScheme()) }, func() *Optizz {
+			o := New()
+			o.SecurityScheme("key", BearerScheme("JWT"))
+			return o
+		}(), "security scheme key is already documented with a different definition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := New()
+			tt.setup(f)
+			paths := len(f.Generator().API().Paths)
+
+			err := f.Mount("/billing", tt.other)
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("got error %v, want %s", err, tt.err)
+			}
+			if len(f.Generator().API().Paths) != paths {
+				t.Error("the specification was merged despite the conflict")
+			}
+		})
+	}
+}
+
+func TestMount_RenameSchemas(t *testing.T) {
+	f := New()
+	otherInvoice(f)
+	if err := f.Mount("/billing/v1", mountInstance("/list", "listBilling"), RenameSchemas()); err != nil {
+		t.Fatal(err)
+	}
+	api := f.Generator().API()
+	if _, ok := api.Components.Schemas["BillingV1OptizzInvoice"]; !ok {
+		t.Fatalf("got schemas %v, want BillingV1OptizzInvoice", api.Components.Schemas)
+	}
+	b, err := json.Marshal(api.Paths["/billing/v1/list"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"$ref":"#/components/schemas/BillingV1OptizzInvoice"`) {
+		t.Errorf("got path item %s, want a reference to BillingV1OptizzInvoice", b)
+	}
+	if b, _ := json.Marshal(api.Paths["/invoices"]); !strings.Contains(string(b), `"$ref":"#/components/schemas/OptizzInvoice"`) {
+		t.Errorf("got path item %s, want a reference to OptizzInvoice", b)
+	}
+}
+
+func TestMount_Contract(t *testing.T) {
+	other, err := FromSpec(fiber.New(), []byte(contractSpec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := New()
+	if err := f.Mount("/v2", other); err != nil {
+		t.Fatal(err)
+	}
+	f.Get("/openapi.json", nil, f.OpenAPI(nil, "json"))
+
+	_, body := serve(t, f.App(), testRequest{target: "/openapi.json"})
+	for _, want := range []string{`"/v2/pets":`, `"minimum":0.5`, `"requestBodies":{"NewPet":`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got specification %s, want %s", body, want)
+		}
+	}
+}
+
+// tagHooks returns hooks that render the errors with the
+// given status and the outputs as text, prefixed with tag.
+func tagHooks(tag string, status int) Hooks {
+	return Hooks{
+		Error: func(c *fiber.Ctx, err error) (int, interface{}) {
+			return status, tag + " error: " + err.Error()
+		},
+		Render: func(c *fiber.Ctx, code int, payload interface{}) {
+			if s, ok := payload.(*string); ok {
+				payload = *s
+			}
+			c.Status(code).SendString(fmt.Sprintf("%s %v", tag, payload))
+		},
+	}
+}
+
+func TestHooks_PerInstance(t *testing.T) {
+	fail := func() *OptizzHandler {
+		return HOut(func(c *fiber.Ctx) (*string, error) { return nil, errors.New("boom") }, 200)
+	}
+	ok := func() *OptizzHandler {
+		return HOut(func(c *fiber.Ctx) (*string, error) { s := "ok"; return &s, nil }, 200)
+	}
+
+	a := New(WithHooks(tagHooks("a", 418)))
+	a.Get("/fail", fail())
+	a.Get("/ok", ok())
+	b := New()
+	b.SetHooks(tagHooks("b", 503))
+	b.Get("/fail", fail())
+	b.Get("/ok", ok())
+
+	tests := []struct {
+		name   string
+		f      *Optizz
+		target string
+		status int
+		body   string
+	}{
+		{"a error", a, "/fail", 418, "a a error: boom"},
+		{"a render", a, "/ok", 200, "a ok"},
+		{"b error", b, "/fail", 503, "b b error: boom"},
+		{"b render", b, "/ok", 200, "b ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := serve(t, tt.f.App(), testRequest{target: tt.target})
+			if resp.StatusCode != tt.status || body != tt.body {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+		})
+	}
+}
+
+func TestHooks_Groups(t *testing.T) {
+	fail := func() *OptizzHandler {
+		return HOut(func(c *fiber.Ctx) (*string, error) { return nil, errors.New("boom") }, 200)
+	}
+
+	f := New(WithHooks(tagHooks("public", 400)))
+	f.Get("/fail", fail())
+	admin := f.Group("/admin", "admin", "Admin")
+	admin.SetHooks(Hooks{Error: func(c *fiber.Ctx, err error) (int, interface{}) {
+		return 500, "admin error: " + err.Error()
+	}})
+	admin.Get("/fail", fail())
+	// The sub-group inherits the hooks of the admin
+	// group, including those the admin group inherits.
+	users := admin.Group("/users", "users", "Users")
+	users.Get("/fail", fail())
+	// A group created before its parent changes its
+	// hooks sees the change.
+	other := f.Group("/other", "other", "Other")
+	other.Get("/fail", fail())
+	other.SetHooks(Hooks{Render: func(c *fiber.Ctx, code int, payload interface{}) {
+		c.Status(code).SendString(fmt.Sprintf("other %v", payload))
+	}})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/fail", 400, "public public error: boom"},
+		{"/admin/fail", 500, "public admin error: boom"},
+		{"/admin/users/fail", 500, "public admin error: boom"},
+		{"/other/fail", 400, "other public error: boom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resp, body := serve(t, f.App(), testRequest{target: tt.target})
+			if resp.StatusCode != tt.status || body != tt.body {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, body, tt.status, tt.body)
+			}
+		})
+	}
+	if h := users.Hooks(); h.Error == nil || funcEqual(h.Error, DefaultErrorHook) {
+		t.Error("the sub-group does not inherit the error hook of its parent")
+	}
+	if h := New().Hooks(); !funcEqual(h.Error, DefaultErrorHook) {
+		t.Error("an instance without hooks does not use the defaults")
+	}
+}
+
+type typedPetInput struct {
+	ID    int    `path:"id"`
+	Limit int    `query:"limit" validate:"omitempty,max=10"`
+	Name  string `json:"name" validate:"required"`
+}
+
+type typedPet struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func typedPetHandler(c *fiber.Ctx, in *typedPetInput) (*typedPet, error) {
+	if in.Name == "fail" {
+		return nil, Conflict("pet exists")
+	}
+	if in.Name == "none" {
+		return nil, nil
+	}
+	return &typedPet{ID: in.ID, Name: in.Name}, nil
+}
+
+func typedPetInHandler(c *fiber.Ctx, in *typedPetInput) error {
+	_, err := typedPetHandler(c, in)
+	return err
+}
+
+func typedPetOutHandler(c *fiber.Ctx) (*typedPet, error) {
+	if c.Query("fail") != "" {
+		return nil, errors.New("boom")
+	}
+	return &typedPet{ID: 1, Name: "rex"}, nil
+}
+
+func TestTypedHandlers(t *testing.T) {
+	f := New()
+	f.Put("/reflect/:id", Handler(typedPetHandler, 200))
+	f.Put("/typed/:id", H(typedPetHandler, 200))
+	f.Put("/reflect-in/:id", Handler(typedPetInHandler, 204))
+	f.Put("/typed-in/:id", HIn(typedPetInHandler, 204))
+	f.Put("/reflect-out/:id", Handler(typedPetOutHandler, 200))
+	f.Put("/typed-out/:id", HOut(typedPetOutHandler, 200))
+
+	tests := []struct {
+		name   string
+		path   string
+		query  string
+		body   string
+		status int
+		resp   string
+	}{
+		{"bind", "", "?limit=5", `{"name":"rex"}`, 200, `{"id":7,"name":"rex"}`},
+		{"nil output", "", "", `{"name":"none"}`, 200, `null`},
+		{"bind error", "", "?limit=five", `{"name":"rex"}`, 400, `"field":"limit"`},
+		{"validation error", "", "?limit=50", `{"name":"rex"}`, 400, `"field":"limit"`},
+		{"required", "", "", `{}`, 400, `"field":"name"`},
+		{"malformed body", "", "", `{"name":`, 400, `error parsing request body`},
+		{"handler error", "", "", `{"name":"fail"}`, 409, `pet exists`},
+		{"in", "-in", "", `{"name":"rex"}`, 204, ``},
+		{"in bind error", "-in", "?limit=five", `{"name":"rex"}`, 400, `"field":"limit"`},
+		{"in handler error", "-in", "", `{"name":"fail"}`, 409, `pet exists`},
+		{"out", "-out", "", "", 200, `{"id":1,"name":"rex"}`},
+		{"out handler error", "-out", "?fail=1", "", 400, `boom`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var bodies [2]string
+			for i, kind := range []string{"reflect", "typed"} {
+				resp, body := serve(t, f.App(), testRequest{
+					method: fiber.MethodPut,
+					target: "/" + kind + tt.path + "/7" + tt.query,
+					header: map[string]string{fiber.HeaderContentType: fiber.MIMEApplicationJSON},
+					body:   tt.body,
+				})
+				if resp.StatusCode != tt.status {
+					t.Fatalf("%s: got status %d, want %d: %s", kind, resp.StatusCode, tt.status, body)
+				}
+				if !strings.Contains(body, tt.resp) {
+					t.Errorf("%s: got body %s, want %s", kind, body, tt.resp)
+				}
+				bodies[i] = body
+			}
+			if bodies[0] != bodies[1] {
+				t.Errorf("got typed body %s, want %s", bodies[1], bodies[0])
+			}
+		})
+	}
+
+	// Both forms are documented alike.
+	paths := f.Generator().API().Paths
+	for _, suffix := range []string{"", "-in", "-out"} {
+		r, typed := paths["/reflect"+suffix+"/{id}"].PUT, paths["/typed"+suffix+"/{id}"].PUT
+		if !equalJSON(r.Parameters, typed.Parameters) || !equalJSON(r.Responses, typed.Responses) ||
+			(r.RequestBody == nil) != (typed.RequestBody == nil) {
+			t.Errorf("the typed operation %s is not documented like the reflected one", typed.ID)
+		}
+	}
+}
+
+func TestTypedHandlers_InvalidInput(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	H(func(c *fiber.Ctx, in *string) (*string, error) { return in, nil }, 200)
+}
+
+type routeName struct {
+	Path  string `json:"path"`
+	Group string `json:"group"`
+}
+
+func TestRoutes(t *testing.T) {
+	f := New()
+	var fromMiddleware *Route
+	f.App().Use(func(c *fiber.Ctx) error {
+		fromMiddleware, _ = f.RouteFromContext(c)
+		return c.Next()
+	})
+	fromHandler := func(c *fiber.Ctx) (*routeName, error) {
+		r, err := f.RouteFromContext(c)
+		if err != nil {
+			return nil, err
+		}
+		return &routeName{Path: r.GetPath(), Group: r.GetGroup()}, nil
+	}
+	list := HOut(fromHandler, 200, ID("listPets"))
+	create := HOut(fromHandler, 200, ID("createUser"))
+	f.Get("/pets", list)
+	f.Group("/admin", "admin", "administration").Post("/users", create)
+
+	routes := f.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("got %d routes, want 2", len(routes))
+	}
+	for i, want := range []string{"GET /pets", "POST /admin/users"} {
+		if got := routes[i].GetVerb() + " " + routes[i].GetPath(); got != want {
+			t.Errorf("got route %d %s, want %s", i, got, want)
+		}
+	}
+	routes[0] = nil
+	if f.Routes()[0] == nil {
+		t.Error("Routes returned the registry itself")
+	}
+
+	if r, err := f.RouteByOperationID("createUser"); err != nil || r.GetPath() != "/admin/users" || r.GetGroup() != "admin" {
+		t.Errorf("got route %v, error %v", r, err)
+	}
+	if _, err := f.RouteByOperationID("deletePet"); err == nil {
+		t.Error("got no error for an unknown operation")
+	}
+	if r, err := f.GetRouteByHandler(create); err != nil || r.GetOperationID() != "createUser" {
+		t.Errorf("got route %v, error %v", r, err)
+	}
+	if _, err := f.GetRouteByHandler(HOut(fromHandler, 200)); err == nil {
+		t.Error("got no error for an unregistered handler")
+	}
+
+	_, body := serve(t, f.App(), testRequest{method: "POST", target: "/admin/users"})
+	if !equalJSON(body, `{"path":"/admin/users","group":"admin"}`) {
+		t.Errorf("got body %s", body)
+	}
+	if fromMiddleware == nil || fromMiddleware.GetOperationID() != "createUser" {
+		t.Errorf("got route %v from the middleware, want createUser", fromMiddleware)
+	}
+	serve(t, f.App(), testRequest{target: "/unknown"})
+	if fromMiddleware != nil {
+		t.Errorf("got route %v for an unknown path", fromMiddleware)
+	}
+}
+
+type tagGroupsDoc struct {
+	Paths map[string]map[string]struct {
+		Tags []string `json:"tags" yaml:"tags"`
+	} `json:"paths" yaml:"paths"`
+	TagGroups []struct {
+		Name string   `json:"name" yaml:"name"`
+		Tags []string `json:"tags" yaml:"tags"`
+	} `json:"x-tagGroups" yaml:"x-tagGroups"`
+}
+
+func TestRouterGroup_InheritTags(t *testing.T) {
+	f := New()
+	store := f.Group("/store", "store", "The store")
+	orders := store.Group("/orders", "orders", "The orders").InheritTags()
+	orders.Group("/items", "items", "The items").InheritTags().
+		Get("/", H(func(c *fiber.Ctx, in *authUser) (*authUser, error) { return in, nil }, 200, AddTags("orders", "extra", "items")))
+	store.Group("/admin", "admin", "The administration").Get("/", echo[authUser]())
+	f.Group("/pets", "pets", "The pets").Get("/", echo[authUser]())
+	f.App().Get("/openapi.json", f.OpenAPI(nil, "json"))
+	f.App().Get("/openapi.yaml", f.OpenAPI(nil, "yaml"))
+
+	for _, format := range []string{"json", "yaml"} {
+		t.Run(format, func(t *testing.T) {
+			_, body := serve(t, f.App(), testRequest{target: "/openapi." + format})
+			var doc tagGroupsDoc
+			var err error
+			if format == "json" {
+				err = json.Unmarshal([]byte(body), &doc)
+			} else {
+				err = yaml.Unmarshal([]byte(body), &doc)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for path, want := range map[string][]string{
+				"/store/orders/items/": {"store", "orders", "items", "extra"},
+				"/store/admin/":        {"admin"},
+				"/pets/":               {"pets"},
+			} {
+				if got := doc.Paths[path]["get"].Tags; !reflect.DeepEqual(got, want) {
+					t.Errorf("got tags %v for %s, want %v", got, path, want)
+				}
+			}
+			groups := make(map[string][]string)
+			for _, tg := range doc.TagGroups {
+				groups[tg.Name] = tg.Tags
+			}
+			want := map[string][]string{
+				"store": {"store", "orders", "items", "admin"},
+				"pets":  {"pets"},
+				"extra": {"extra"},
+			}
+			if !reflect.DeepEqual(groups, want) {
+				t.Errorf("got tag groups %v, want %v", groups, want)
+			}
+		})
+	}
+}
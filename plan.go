@@ -0,0 +1,242 @@
+package optizz
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// bindingPlan is the binding of the request parameters to
+// the fields of an input type. It is compiled once per type,
+// so that binding a request does not walk the struct fields
+// and parse their tags.
+type bindingPlan struct {
+	// embedded are the index sequences of the embedded
+	// struct pointers, allocated before binding.
+	embedded [][]int
+
+	// params are the fields bound from each
+	// location, keyed by location tag.
+	params map[string][]*paramPlan
+}
+
+// paramPlan is the binding of a struct
+// field from a request parameter.
+type paramPlan struct {
+	index []int
+	field string
+	owner reflect.Type
+
+	// name, required and tagDefault are parsed from the
+	// location tag, and err is set if it is malformed.
+	name       string
+	required   bool
+	tagDefault string
+	err        error
+
+	defaults   []string
+	enum       []string
+	explode    bool
+	deepObject bool
+
+	// kind is the kind of the field, with pointers
+	// dereferenced, and decode converts a value to
+	// the field, or to an element of a slice or array.
+	kind   reflect.Kind
+	decode decoder
+}
+
+// A decoder converts and binds a string
+// value to a reflected value.
+type decoder func(string, reflect.Value) error
+
+var (
+	plans sync.Map
+
+	paramLocations = []string{QueryTag, PathTag, HeaderTag, CookieTag}
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// planOf returns the binding plan of the input
+// type t, compiling it on first use.
+func planOf(t reflect.Type) *bindingPlan {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if p, ok := plans.Load(t); ok {
+		return p.(*bindingPlan)
+	}
+	p, _ := plans.LoadOrStore(t, compilePlan(t))
+	return p.(*bindingPlan)
+}
+
+func compilePlan(t reflect.Type) *bindingPlan {
+	p := &bindingPlan{params: make(map[string][]*paramPlan)}
+	p.compile(t, nil)
+	return p
+}
+
+func (p *bindingPlan) compile(t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+
+		// Handle embedded structs with a recursive call.
+		// Nil pointers to embedded structs are
+		// allocated before binding.
+		if ft.Anonymous {
+			et := ft.Type
+			if et.Kind() == reflect.Ptr {
+				et = et.Elem()
+				if et.Kind() == reflect.Struct {
+					p.embedded = append(p.embedded, idx)
+				}
+			}
+			if et.Kind() == reflect.Struct {
+				p.compile(et, idx)
+			}
+			continue
+		}
+		for _, loc := range paramLocations {
+			if tag := ft.Tag.Get(loc); tag != "" {
+				p.params[loc] = append(p.params[loc], compileParam(t, ft, idx, loc, tag))
+			}
+		}
+	}
+}
+
+// compileParam returns the binding of the field sf of the
+// struct type owner from the parameter described by tag.
+func compileParam(owner reflect.Type, sf reflect.StructField, index []int, loc, tag string) *paramPlan {
+	pp := &paramPlan{
+		index:   index,
+		field:   sf.Name,
+		owner:   owner,
+		explode: true,
+	}
+	var style string
+	pp.name, pp.required, pp.tagDefault, style, pp.err = parseTagKey(tag)
+
+	if v, ok := sf.Tag.Lookup(ExplodeTag); ok {
+		if explode, err := strconv.ParseBool(v); err == nil && !explode {
+			pp.explode = false
+		}
+	}
+	pp.deepObject = loc == QueryTag && paramStyle(sf, style) == DeepObjectStyle
+
+	if def, ok := sf.Tag.Lookup(DefaultTag); ok {
+		if pp.explode {
+			pp.defaults = strings.Split(def, ",")
+		} else {
+			pp.defaults = []string{def}
+		}
+	}
+	if enum := sf.Tag.Get(EnumTag); enum != "" {
+		pp.enum = strings.Split(strings.TrimSpace(enum), ",")
+	}
+	t := sf.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	pp.kind = t.Kind()
+	if pp.kind == reflect.Slice || pp.kind == reflect.Array {
+		t = t.Elem()
+	}
+	pp.decode = decoderOf(t)
+
+	return pp
+}
+
+// allocEmbedded allocates the nil pointers to the
+// embedded structs of the struct value v.
+func (p *bindingPlan) allocEmbedded(v reflect.Value) {
+	for _, idx := range p.embedded {
+		f, err := v.FieldByIndexErr(idx)
+		if err != nil || !f.IsNil() || !f.CanSet() {
+			continue
+		}
+		f.Set(reflect.New(f.Type().Elem()))
+	}
+}
+
+// resetCookies sets the fields of the struct value v that
+// are bound from the cookies to their zero value, such
+// as those decoded from the body.
+func (p *bindingPlan) resetCookies(v reflect.Value) {
+	for _, pp := range p.params[CookieTag] {
+		f, err := v.FieldByIndexErr(pp.index)
+		if err != nil || !f.CanSet() {
+			continue
+		}
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
+// decoderOf returns the decoder of the values of type t,
+// which is chosen with the same rules as bindStringValue.
+func decoderOf(t reflect.Type) decoder {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return func(s string, v reflect.Value) error {
+			u := reflect.New(t)
+			if err := u.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return err
+			}
+			v.Set(u.Elem())
+			return nil
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(s string, v reflect.Value) error {
+			v.SetString(s)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits := t.Bits()
+		return func(s string, v reflect.Value) error {
+			i, err := strconv.ParseInt(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bits := t.Bits()
+		return func(s string, v reflect.Value) error {
+			i, err := strconv.ParseUint(s, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetUint(i)
+			return nil
+		}
+	case reflect.Bool:
+		return func(s string, v reflect.Value) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(s string, v reflect.Value) error {
+			f, err := strconv.ParseFloat(s, bits)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	}
+	kind := t.Kind()
+	return func(string, reflect.Value) error {
+		return fmt.Errorf("unsupported parameter type: %v", kind)
+	}
+}
@@ -0,0 +1,144 @@
+package optizz
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+)
+
+// ProblemMediaType is the media type of the problem
+// documents defined by RFC 7807.
+const ProblemMediaType = "application/problem+json"
+
+// ProblemDetails represents a problem document, as defined
+// by RFC 7807. Field-level validation failures are reported
+// in the errors extension member.
+type ProblemDetails struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Status   int           `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Instance string        `json:"instance,omitempty"`
+	Code     string        `json:"code,omitempty"`
+	Errors   []*FieldError `json:"errors,omitempty"`
+}
+
+// MediaType implements the mediaTyper interface
+// for ProblemDetails.
+func (*ProblemDetails) MediaType() string {
+	return ProblemMediaType
+}
+
+// FieldError represents the validation failure
+// of a single field of a handler input. Field is the
+// wire name of the field, and Location is either
+// query, path, header, cookie or body.
+type FieldError struct {
+	Field    string `json:"field"`
+	Location string `json:"location,omitempty" enum:"query,path,header,cookie,body"`
+	Tag      string `json:"tag,omitempty"`
+	Param    string `json:"param,omitempty"`
+	Message  string `json:"message"`
+}
+
+// ProblemDetailsErrorHook is an error hook that renders
+// errors as RFC 7807 problem documents. It uses the status
+// code of an HTTPError, and reports the validation failures
+// of a BindError in the errors extension member. The other
+// errors are server faults, rendered as a 500 that does not
+// disclose their message.
+// When it is the error hook of a group, the error responses
+// of the operations of the group are documented with the
+// ProblemDetails schema.
+func ProblemDetailsErrorHook(c *fiber.Ctx, e error) (int, interface{}) {
+	pd := &ProblemDetails{
+		Type:     "about:blank",
+		Status:   http.StatusInternalServerError,
+		Detail:   http.StatusText(http.StatusInternalServerError),
+		Instance: c.Path(),
+	}
+	var (
+		he *HTTPError
+		be BindError
+	)
+	switch {
+	case errors.As(e, &he):
+		pd.Status = he.status()
+		pd.Detail = he.Message
+		pd.Code = he.Code
+	case errors.As(e, &be):
+		pd.Status = http.StatusBadRequest
+		pd.Detail = e.Error()
+		pd.Errors = be.FieldErrors()
+		if be.ValidationErrors() != nil {
+			pd.Detail = "the request input failed validation"
+		}
+	}
+	pd.Title = http.StatusText(pd.Status)
+
+	return pd.Status, pd
+}
+
+// problemResponses returns a copy of the responses of an
+// operation where the error responses that use the shared
+// error schema, or no schema, use the ProblemDetails schema
+// instead. A default response is added if none exists.
+// It also returns the codes of these responses.
+func problemResponses(responses []*openapi.OperationResponse) ([]*openapi.OperationResponse, []string) {
+	var (
+		ret   = make([]*openapi.OperationResponse, 0, len(responses)+1)
+		codes []string
+		dflt  bool
+	)
+	for _, r := range responses {
+		if r == nil {
+			continue
+		}
+		if isErrorCode(r.Code) && (r.Model == nil || reflect.TypeOf(r.Model) == reflect.TypeOf(HTTPError{})) {
+			cpy := *r
+			cpy.Model = ProblemDetails{}
+			r = &cpy
+			codes = append(codes, r.Code)
+		}
+		if r.Code == "default" {
+			dflt = true
+		}
+		ret = append(ret, r)
+	}
+	if !dflt {
+		ret = append(ret, &openapi.OperationResponse{
+			Code:        "default",
+			Description: "Problem",
+			Model:       ProblemDetails{},
+		})
+		codes = append(codes, "default")
+	}
+	return ret, codes
+}
+
+// setProblemMediaType sets the media type of the
+// responses of the operation with the given codes
+// to the problem media type.
+func setProblemMediaType(op *openapi.Operation, codes []string) {
+	for _, code := range codes {
+		r, ok := op.Responses[code]
+		if !ok || r.Response == nil {
+			continue
+		}
+		for mt, content := range r.Content {
+			if mt != ProblemMediaType {
+				delete(r.Content, mt)
+				r.Content[ProblemMediaType] = content
+			}
+		}
+	}
+}
+
+// isErrorCode returns whether the response code
+// of an operation is an error code.
+func isErrorCode(code string) bool {
+	return code == "default" || (len(code) == 3 && (code[0] == '4' || code[0] == '5'))
+}
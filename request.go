@@ -0,0 +1,423 @@
+package optizz
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+)
+
+// routeMatcher matches the path of the requests
+// against a path template of the specification.
+type routeMatcher struct {
+	path     string
+	segments []string
+	slash    bool
+	params   int
+	item     *openapi.PathItem
+}
+
+// match returns whether the segments of the path match the
+// template, and the values of its parameters by name. Like
+// the router of the Fiber app, it compares the static
+// segments regardless of case unless caseSensitive, and
+// the trailing slash of the path only if strict.
+func (rm *routeMatcher) match(segments []string, slash, caseSensitive, strict bool) (map[string]string, bool) {
+	if len(segments) != len(rm.segments) || (strict && slash != rm.slash) {
+		return nil, false
+	}
+	var values map[string]string
+	for i, s := range rm.segments {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			if values == nil {
+				values = make(map[string]string, rm.params)
+			}
+			values[s[1:len(s)-1]] = segments[i]
+			continue
+		}
+		if s != segments[i] && (caseSensitive || !strings.EqualFold(s, segments[i])) {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// routeIndex is the index of the path templates of
+// the specification, rebuilt when it changes.
+type routeIndex struct {
+	mu       sync.RWMutex
+	version  uint64
+	paths    int
+	matchers []*routeMatcher
+}
+
+// lookupOperation returns the operation of the specification that
+// matches the method and path of a request, and the values
+// of its path parameters.
+func (f *Optizz) lookupOperation(method, path string) (*openapi.Operation, map[string]string) {
+	_, op, values := f.lookupPathItem(method, path)
+	return op, values
+}
+
+// lookupPathItem is lookupOperation, that also returns the
+// matcher of the path template of the operation, which
+// holds its path item.
+func (f *Optizz) lookupPathItem(method, path string) (*routeMatcher, *openapi.Operation, map[string]string) {
+	ri := f.routes
+	version := f.spec.version()
+	paths := f.gen.API().Paths
+
+	// The operations added to the generator directly
+	// do not invalidate the specification, but add
+	// a path most of the time.
+	ri.mu.RLock()
+	stale := ri.version != version || ri.paths != len(paths)
+	ri.mu.RUnlock()
+
+	if stale {
+		ri.mu.Lock()
+		ri.matchers = ri.matchers[:0]
+		for p, item := range paths {
+			rm := &routeMatcher{path: p, segments: splitPath(p), slash: hasTrailingSlash(p), item: item}
+			for _, s := range rm.segments {
+				if strings.HasPrefix(s, "{") {
+					rm.params++
+				}
+			}
+			ri.matchers = append(ri.matchers, rm)
+		}
+		// Static paths take precedence
+		// over the templated ones.
+		sort.SliceStable(ri.matchers, func(i, j int) bool {
+			return ri.matchers[i].params < ri.matchers[j].params
+		})
+		ri.version, ri.paths = version, len(paths)
+		ri.mu.Unlock()
+	}
+	ri.mu.RLock()
+	defer ri.mu.RUnlock()
+
+	cfg := f.app.Config()
+	segments, slash := splitPath(path), hasTrailingSlash(path)
+	for _, rm := range ri.matchers {
+		values, ok := rm.match(segments, slash, cfg.CaseSensitive, cfg.StrictRouting)
+		if !ok {
+			continue
+		}
+		if op := pathOperation(rm.item, method); op != nil {
+			return rm, op, values
+		}
+	}
+	return nil, nil, nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func hasTrailingSlash(path string) bool {
+	return len(path) > 1 && strings.HasSuffix(path, "/")
+}
+
+// pathMethods are the HTTP methods
+// of the operations of a path item.
+var pathMethods = []string{
+	fiber.MethodGet, fiber.MethodPut, fiber.MethodPost, fiber.MethodDelete,
+	fiber.MethodOptions, fiber.MethodHead, fiber.MethodPatch, fiber.MethodTrace,
+}
+
+// pathOperation returns the operation of
+// the path item for the HTTP method.
+func pathOperation(item *openapi.PathItem, method string) *openapi.Operation {
+	switch method {
+	case fiber.MethodGet:
+		return item.GET
+	case fiber.MethodPut:
+		return item.PUT
+	case fiber.MethodPost:
+		return item.POST
+	case fiber.MethodDelete:
+		return item.DELETE
+	case fiber.MethodOptions:
+		return item.OPTIONS
+	case fiber.MethodHead:
+		return item.HEAD
+	case fiber.MethodPatch:
+		return item.PATCH
+	case fiber.MethodTrace:
+		return item.TRACE
+	}
+	return nil
+}
+
+// pathOperations returns the operations of the path item.
+func pathOperations(item *openapi.PathItem) []*openapi.Operation {
+	var ops []*openapi.Operation
+	for _, method := range pathMethods {
+		if op := pathOperation(item, method); op != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+// operationParameters returns the parameters of the operation
+// op of the path item, with their references resolved in the
+// components. The parameters of the operation override the
+// ones of the path item with the same name and location.
+func operationParameters(components *openapi.Components, item *openapi.PathItem, op *openapi.Operation) []*openapi.Parameter {
+	var params []*openapi.Parameter
+	seen := make(map[string]bool)
+
+	lists := [][]*openapi.ParameterOrRef{op.Parameters}
+	if item != nil {
+		lists = append(lists, item.Parameters)
+	}
+	for _, list := range lists {
+		for _, por := range list {
+			for i := 0; por != nil && por.Parameter == nil && por.Reference != nil && components != nil && i < 32; i++ {
+				por = components.Parameters[strings.TrimPrefix(por.Reference.Ref, "#/components/parameters/")]
+			}
+			if por == nil || por.Parameter == nil {
+				continue
+			}
+			p := por.Parameter
+			if key := p.In + ":" + p.Name; !seen[key] {
+				seen[key] = true
+				params = append(params, p)
+			}
+		}
+	}
+	return params
+}
+
+// operationRoute is the route of an operation, whose
+// scope renders the errors of the validation of its
+// requests, and whose input type binds them.
+type operationRoute struct {
+	scope *scope
+	in    reflect.Type
+}
+
+// ValidateRequests returns a middleware that validates the
+// requests against the operation of the OpenAPI specification
+// that matches their method and path. It checks the presence
+// and the schema of the parameters, such as their type, enum,
+// format, pattern and bounds, and the schema of the JSON
+// body. It protects the routes registered on the Fiber app
+// directly, as long as their operation is documented.
+// The requests that fail the validation are rejected with
+// a BindError, and the bodies of a media type that is not
+// documented with a 415 HTTPError, rendered with the hooks
+// of the group of the route of the operation, or those of
+// the instance if it has none.
+func (f *Optizz) ValidateRequests() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		rm, op, pathValues := f.lookupPathItem(c.Method(), c.Path())
+		if op == nil {
+			return c.Next()
+		}
+		components := f.gen.API().Components
+		sv := &schemaValidator{components: components, written: f.specExt.contractComponents}
+
+		route := f.opRoutes[operationKey{path: rm.path, method: strings.ToLower(c.Method())}]
+		if route == nil {
+			route = &operationRoute{scope: f.RouterGroup.scope}
+		}
+		// The operations of the specification loaded with FromSpec
+		// are validated against their schemas as written too.
+		wop, witem := f.specExt.writtenOperation(rm.path, c.Method())
+		for _, p := range operationParameters(components, rm.item, op) {
+			wp := sv.writtenParameter(wop, witem, p)
+			validateParameter(c, sv, p, wp["schema"], parameterExplode(p, wp, route.in), pathValues)
+		}
+		if op.RequestBody != nil {
+			if err := validateBody(c, sv, op.RequestBody, wop); err != nil {
+				route.scope.handleError(c, err)
+				return nil
+			}
+		}
+		if len(sv.errs) != 0 {
+			err := BindError{message: "the request does not match the specification", fieldErrs: sv.errs}
+			route.scope.handleError(c, err)
+			return nil
+		}
+		return c.Next()
+	}
+}
+
+// parameterExplode returns whether the values of the array
+// query parameter p, written as wp if any, are exploded: as
+// written, as bound by the field of the input type in that
+// binds it, or else the default of OpenAPI, which explodes
+// the parameters of the form style.
+func parameterExplode(p *openapi.Parameter, wp map[string]interface{}, in reflect.Type) bool {
+	if explode, ok := wp["explode"].(bool); ok {
+		return explode
+	}
+	if p.Explode {
+		return true
+	}
+	if in != nil && derefType(in).Kind() == reflect.Struct {
+		for _, pp := range planOf(in).params[QueryTag] {
+			if pp.name == p.Name {
+				return pp.explode
+			}
+		}
+	}
+	return p.Style == "" || p.Style == "form"
+}
+
+// validateParameter validates the value of the parameter
+// p of the request against its schema, and its written
+// schema w, if any. The values of an array query parameter
+// that is not exploded are comma-separated.
+func validateParameter(c *fiber.Ctx, sv *schemaValidator, p *openapi.Parameter, w interface{}, explode bool, pathValues map[string]string) {
+	var values []string
+
+	switch p.In {
+	case QueryTag:
+		// The deepObject parameters are
+		// validated by the binding.
+		if p.Style == DeepObjectStyle {
+			return
+		}
+		for _, v := range c.Context().QueryArgs().PeekMulti(p.Name) {
+			if len(v) != 0 {
+				values = append(values, string(v))
+			}
+		}
+	case PathTag:
+		if v, ok := pathValues[p.Name]; ok && v != "" {
+			values = []string{v}
+		}
+	case HeaderTag:
+		if v := c.Get(p.Name); v != "" {
+			values = []string{v}
+		}
+	case CookieTag:
+		if v := c.Cookies(p.Name); v != "" {
+			values = []string{v}
+		}
+	}
+	sv.location = p.In
+
+	if len(values) == 0 {
+		if p.Required {
+			sv.fail(p.Name, "required", "", "is required")
+		}
+		return
+	}
+	schema := sv.resolve(p.Schema)
+	if schema == nil {
+		return
+	}
+	if schema.Type != "array" {
+		if len(values) > 1 {
+			sv.fail(p.Name, "type", schema.Type, "must be a single value")
+			return
+		}
+		sv.validate(p.Schema, w, coerceValue(sv.resolve(p.Schema), values[0]), p.Name)
+		return
+	}
+	// Array values are either repeated, or comma-separated
+	// unless the form style is exploded.
+	if len(values) == 1 && !(p.In == QueryTag && explode) {
+		values = strings.Split(values[0], ",")
+	}
+	items := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		items = append(items, coerceValue(sv.resolve(schema.Items), v))
+	}
+	sv.validate(p.Schema, w, items, p.Name)
+}
+
+// coerceValue converts the string value of a parameter to
+// the type of the schema s, as if it was decoded from JSON.
+// Values that cannot be converted are kept as strings, and
+// fail the validation of the type.
+func coerceValue(s *openapi.Schema, v string) interface{} {
+	if s == nil {
+		return v
+	}
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return json.Number(v)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// validateBody validates the body of the request against
+// the schema of the request body, and the schema of the
+// written operation op, if any. The bodies of the media
+// types that do not decode to generic values, such as
+// forms and XML, are not validated. It returns a 415
+// HTTPError if the media type of the body is not
+// documented.
+func validateBody(c *fiber.Ctx, sv *schemaValidator, rb *openapi.RequestBody, op map[string]interface{}) error {
+	sv.location = "body"
+
+	body := c.Body()
+	if len(body) == 0 {
+		if rb.Required {
+			sv.fail("", "required", "", "the request body is required")
+		}
+		return nil
+	}
+	mt := normalizeMediaType(string(c.Request().Header.ContentType()))
+	content, ok := rb.Content[mt]
+	if !ok {
+		// The routes registered on the Fiber app
+		// directly have no binding to reject them.
+		return unsupportedMediaType(mt)
+	}
+	if content == nil {
+		return nil
+	}
+	var doc interface{}
+	switch {
+	case isJSONMediaType(mt):
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			sv.fail("", "type", "", "malformed JSON body: %s", err)
+			return nil
+		}
+	case mt == MessagePackMediaType || mt == CBORMediaType:
+		cd, ok := codecs.lookup(mt)
+		if !ok {
+			return nil
+		}
+		var v interface{}
+		if err := cd.Unmarshal(body, &v); err != nil {
+			sv.fail("", "type", "", "malformed body: %s", err)
+			return nil
+		}
+		// Normalize the decoded value through JSON.
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return nil
+		}
+	default:
+		return nil
+	}
+	sv.validate(content.Schema, sv.writtenBodySchema(op, mt), doc, "")
+	return nil
+}
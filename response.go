@@ -0,0 +1,122 @@
+package optizz
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+)
+
+// ResponseValidation is the mode of the validation of
+// the outputs of the handlers against the schema of
+// their documented response.
+type ResponseValidation int
+
+// Response validation modes.
+const (
+	// ResponseValidationOff disables the
+	// validation of the responses.
+	ResponseValidationOff ResponseValidation = iota
+	// ResponseValidationLog logs the invalid responses,
+	// which are rendered as usual.
+	ResponseValidationLog
+	// ResponseValidationFail logs the invalid responses,
+	// which are replaced with a 500 error.
+	ResponseValidationFail
+)
+
+// operationSpec is the specification of the operation of
+// a route, and the components its schemas refer to. The
+// operations of the specification loaded with FromSpec
+// also have their written operation and components.
+type operationSpec struct {
+	op         *openapi.Operation
+	components *openapi.Components
+
+	written           map[string]interface{}
+	writtenComponents map[string]interface{}
+}
+
+// validateResponse validates the output val of the handler,
+// rendered with the status code, against its validate tags
+// and the schema of the response of the operation.
+func validateResponse(s *scope, spec *operationSpec, status int, val interface{}) []*FieldError {
+	var errs []*FieldError
+
+	if v := reflect.ValueOf(val); v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+		if err := s.validate().Struct(val); err != nil {
+			be := BindError{validationErr: err, typ: v.Elem().Type()}
+			errs = append(errs, be.FieldErrors()...)
+		}
+	}
+	// Validation tags are not bound to a location.
+	for _, fe := range errs {
+		fe.Location = ""
+	}
+	if spec == nil || spec.op == nil {
+		return errs
+	}
+	r, ok := spec.op.Responses[strconv.Itoa(status)]
+	if !ok || r.Response == nil {
+		return errs
+	}
+	var (
+		sor         *openapi.SchemaOrRef
+		contentType string
+	)
+	for mt, content := range r.Content {
+		if content != nil && content.MediaType != nil && (isJSONMediaType(mt) || sor == nil) {
+			sor, contentType = content.Schema, mt
+		}
+	}
+	if sor == nil {
+		return errs
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		return append(errs, &FieldError{Message: err.Error()})
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return append(errs, &FieldError{Message: err.Error()})
+	}
+	sv := &schemaValidator{components: spec.components, written: spec.writtenComponents, optionalNulls: true}
+	sv.validate(sor, sv.writtenResponseSchema(spec.written, strconv.Itoa(status), contentType), doc, "")
+
+	return append(errs, sv.errs...)
+}
+
+// handleInvalidResponse handles the violations of the schema
+// of the response by the output of the handler according to
+// the mode. The violations are logged with the logger of the
+// scope, and the error that replaces the response in fail
+// mode is returned, without them.
+func handleInvalidResponse(c *fiber.Ctx, s *scope, mode ResponseValidation, spec *operationSpec, errs []*FieldError) error {
+	id := c.Route().Path
+	if spec != nil && spec.op != nil {
+		id = spec.op.ID
+	}
+	msgs := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		if fe.Field != "" {
+			msgs = append(msgs, fe.Field+" "+fe.Message)
+		} else {
+			msgs = append(msgs, fe.Message)
+		}
+	}
+	s.log().Printf("optizz: response of operation %s does not match its schema: %s", id, strings.Join(msgs, "; "))
+
+	if mode == ResponseValidationLog {
+		return nil
+	}
+	return NewHTTPError(http.StatusInternalServerError, "the response does not match the documented schema").
+		WithCode("invalid_response")
+}
@@ -13,8 +13,14 @@ import (
 // RouterGroup is an abstraction of a Fiber router group.
 type RouterGroup struct {
 	app         *fiber.App
+	root        *Optizz
 	group       fiber.Router
 	gen         *openapi.Generator
+	scope       *scope
+	parent      *RouterGroup
+	inheritTags bool
+	security    []SecurityRequirement
+	servers     []*openapi.Server
 	path        string
 	Name        string
 	Description string
@@ -25,10 +31,23 @@ func (g *RouterGroup) Group(path, name, description string, handlers ...fiber.Ha
 	// Create the tag in the specification
 	// for this groups.
 	g.gen.AddTag(name, description)
+	if g.parent != nil {
+		// Group the tags of the nested groups under
+		// the tag of their top-level group.
+		top := g
+		for top.parent.parent != nil {
+			top = top.parent
+		}
+		g.root.specExt.addTagGroup(top.Name, name)
+	}
+	g.root.spec.invalidate()
 
 	return &RouterGroup{
 		app:         g.app,
+		root:        g.root,
 		gen:         g.gen,
+		scope:       newScope(g.scope),
+		parent:      g,
 		group:       g.group.Group(path, handlers...),
 		path:        joinPaths(g.path, path),
 		Name:        name,
@@ -36,6 +55,40 @@ func (g *RouterGroup) Group(path, name, description string, handlers ...fiber.Ha
 	}
 }
 
+// InheritTags tags the operations of the group with the
+// tags of its parent groups too, in addition to its own.
+func (g *RouterGroup) InheritTags() *RouterGroup {
+	g.inheritTags = true
+	return g
+}
+
+// operationTags returns the tags of the operations
+// of the group.
+func (g *RouterGroup) operationTags() []string {
+	var tags []string
+	if g.inheritTags && g.parent != nil {
+		tags = g.parent.operationTags()
+	}
+	if g.Name != "" {
+		tags = append(tags, g.Name)
+	}
+	return tags
+}
+
+// SetHooks overrides the hooks used by the handlers of
+// the group with the non-nil hooks of h. The hooks are
+// inherited by the sub-groups, and take precedence over
+// the process-wide defaults.
+func (g *RouterGroup) SetHooks(h Hooks) {
+	g.scope.setHooks(h)
+}
+
+// Hooks returns the hooks in use by the handlers
+// of the group.
+func (g *RouterGroup) Hooks() Hooks {
+	return g.scope.resolvedHooks()
+}
+
 // Use adds middleware to the group.
 func (g *RouterGroup) Use(handlers ...fiber.Handler) {
 	for _, h := range handlers {
@@ -70,6 +123,11 @@ func (g *RouterGroup) Trace(path string, handler *OptizzHandler, middlewares ...
 
 func (g *RouterGroup) Handle(path, method string, handler *OptizzHandler, middlewares ...fiber.Handler) *RouterGroup {
 	handlers := make([]fiber.Handler, 0)
+	// The security requirements are enforced
+	// before the middlewares of the route.
+	if handler != nil {
+		handlers = append(handlers, g.root.guard(g.scope, g.securityOf(handler.ext)))
+	}
 	if middlewares != nil && len(middlewares) > 0 {
 		handlers = append(handlers, middlewares...)
 	}
@@ -94,21 +152,83 @@ func (g *RouterGroup) Handle(path, method string, handler *OptizzHandler, middle
 			it = reflect.TypeOf(oi.InputModel)
 		}
 
+		// Document the error responses as problem
+		// documents if the group renders them so.
+		var problemCodes []string
+		if funcEqual(g.scope.errorHook(), ProblemDetailsErrorHook) {
+			info := *oi
+			info.Responses, problemCodes = problemResponses(oi.Responses)
+			oi = &info
+		}
+
 		// Consolidate path for OpenAPI spec.
 		operationPath := joinPaths(g.path, path)
 		// Add operation to the OpenAPI spec.
-		_, err := g.gen.AddOperation(operationPath, method, g.Name, it, ri.OutputType(), oi)
+		op, err := g.gen.AddOperation(operationPath, method, g.Name, specInputType(it), ri.OutputType(), oi)
 		if err != nil {
 			panic(fmt.Sprintf("error while generating OpenAPI spec on operation %s %s: %s", method, path, err))
 		}
+		setProblemMediaType(op, problemCodes)
+		op.Tags = g.tags(handler.ext)
+		if it != nil {
+			setParameterStyles(op, it)
+			setFormRequestBody(g.gen.API(), op, it)
+			setCookieParams(g.gen.API(), op, it)
+		}
+		setContentTypes(op, handler.ext.consumes, handler.ext.produces)
+		if security := g.operationSecurity(handler.ext); security != nil {
+			g.root.specExt.setSecurity(g.gen.API(), op, security)
+		}
+		key, _ := operationKeyOf(g.gen.API(), op)
+		g.root.groupOps = append(g.root.groupOps, &groupOperation{group: g, ext: handler.ext, op: op, key: key})
+		g.root.opRoutes[key] = &operationRoute{scope: g.scope, in: it}
+		g.root.documentServers(key.path)
+		if handler.ext.strict(g.scope) {
+			closeRequestBody(g.gen.API(), g.root.specExt, op)
+		}
+		// Routes added after the specification was served
+		// must not be hidden by the cached document.
+		g.root.spec.invalidate()
 
-		handlers = append(handlers, handler.Handler)
+		spec := &operationSpec{op: op, components: g.gen.API().Components}
+		handlers = append(handlers, g.scope.handler(handler, spec))
+		route := newRoute(handler, method, operationPath, g.Name, op)
+		route.security = g.securityOf(handler.ext)
+		g.root.registry.add(route, handler)
 	}
 
 	g.group.Add(method, path, handlers...)
 	return g
 }
 
+// tags returns the tags of an operation of the group with
+// the extended options ext, and adds to the specification
+// those that are not documented yet.
+func (g *RouterGroup) tags(ext *operationExt) []string {
+	return mergeTags(g.gen, g.operationTags(), ext)
+}
+
+// mergeTags returns the tags of an operation with the
+// default tags and the extended options ext, and adds to
+// the specification those that are not documented yet.
+func mergeTags(gen *openapi.Generator, tags []string, ext *operationExt) []string {
+	if ext.setTags {
+		tags = ext.tags
+	}
+	var uniq []string
+	for _, t := range append(append([]string(nil), tags...), ext.addTags...) {
+		if t == "" || contains(uniq, t) {
+			continue
+		}
+		uniq = append(uniq, t)
+
+		if !hasTag(gen.API(), t) {
+			gen.AddTag(t, "")
+		}
+	}
+	return uniq
+}
+
 func joinPaths(abs, rel string) string {
 	if rel == "" {
 		return abs
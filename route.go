@@ -1,10 +1,14 @@
 package optizz
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+	"path"
 	"reflect"
 	"runtime"
 	"strings"
+	"sync"
 )
 
 // A Route contains information about a optic-enabled route.
@@ -16,6 +20,9 @@ type Route struct {
 	summary           string
 	deprecated        bool
 	tags              []string
+	group             string
+	operationID       string
+	security          func() []SecurityRequirement
 
 	// Handler is the route handler.
 	handler reflect.Value
@@ -44,6 +51,21 @@ func (r *Route) GetDescription() string { return r.description }
 // GetSummary returns the summary of the route.
 func (r *Route) GetSummary() string { return r.summary }
 
+// GetGroup returns the name of the group of the route.
+func (r *Route) GetGroup() string { return r.group }
+
+// GetOperationID returns the ID of the operation of the route.
+func (r *Route) GetOperationID() string { return r.operationID }
+
+// GetSecurity returns the security requirements of the route,
+// which is public if they are empty, or nil if none is declared.
+func (r *Route) GetSecurity() []SecurityRequirement {
+	if r.security == nil {
+		return nil
+	}
+	return r.security()
+}
+
 // GetDefaultStatusCode returns the default status code of the route.
 func (r *Route) GetDefaultStatusCode() int { return r.defaultStatusCode }
 
@@ -91,7 +113,7 @@ func (r *Route) HandlerNameWithPackage() string {
 // from one route definition.
 // It uses the first chunk of the path of the route as the tag
 // (for example, in /foo/bar it will return the "foo" tag),
-// unless specific tags have been defined with optic.Tags
+// unless specific tags have been defined with Tags
 func (r *Route) GetTags() []string {
 	if r.tags != nil {
 		return r.tags
@@ -104,26 +126,139 @@ func (r *Route) GetTags() []string {
 	return tags
 }
 
-//// GetRouteByHandler returns the route information of
-//// the given wrapped handler.
-//func GetRouteByHandler(h fiber.Handler, ctx *fiber.Ctx) (*Route, error) {
-//	//ctx := &fiber.Ctx{}
-//	ctx.Locals(wantRouteInfos, "")
-//
-//	funcsMu.Lock()
-//	defer funcsMu.Unlock()
-//	if _, ok := funcs[runtime.FuncForPC(reflect.ValueOf(h).Pointer()).Name()]; !ok {
-//		return nil, errors.New("handler is not wrapped by optic")
-//	}
-//	h(ctx)
-//
-//	i := ctx.Locals(routesInfos)
-//	if i == nil {
-//		return nil, errors.New("failed to retrieve handler infos")
-//	}
-//	route, ok := i.(*Route)
-//	if !ok {
-//		return nil, errors.New("failed to retrieve handler infos")
-//	}
-//	return route, nil
-//}
+// routeRegistry holds the routes registered
+// with the handlers of an Optizz instance.
+type routeRegistry struct {
+	mu        sync.RWMutex
+	routes    []*Route
+	byID      map[string]*Route
+	byPath    map[string]*Route
+	byHandler map[*OptizzHandler]*Route
+}
+
+func newRouteRegistry() *routeRegistry {
+	return &routeRegistry{
+		byID:      make(map[string]*Route),
+		byPath:    make(map[string]*Route),
+		byHandler: make(map[*OptizzHandler]*Route),
+	}
+}
+
+// add records the route r, registered with the handler h.
+func (rr *routeRegistry) add(r *Route, h *OptizzHandler) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rr.routes = append(rr.routes, r)
+	if r.operationID != "" {
+		rr.byID[r.operationID] = r
+	}
+	rr.byPath[routeKey(r.Method, r.Path)] = r
+	if _, ok := rr.byHandler[h]; !ok && h != nil {
+		rr.byHandler[h] = r
+	}
+}
+
+// merge adds the routes of the registry other,
+// mounted under the prefix.
+func (rr *routeRegistry) merge(other *routeRegistry, prefix string) {
+	other.mu.RLock()
+	handlers := make(map[*Route]*OptizzHandler, len(other.byHandler))
+	for h, r := range other.byHandler {
+		handlers[r] = h
+	}
+	routes := append([]*Route(nil), other.routes...)
+	other.mu.RUnlock()
+
+	for _, r := range routes {
+		mounted := *r
+		mounted.Path = joinPaths(prefix, r.Path)
+		rr.add(&mounted, handlers[r])
+	}
+}
+
+// routeKey returns the key of the route with the given
+// method and path, which are compared once cleaned
+// since Fiber and the groups join paths differently.
+func routeKey(method, p string) string {
+	return method + " " + path.Clean("/"+p)
+}
+
+// newRoute returns a copy of the route information of the
+// handler h, registered with the given method, full path,
+// group and operation.
+func newRoute(h *OptizzHandler, method, fullPath, group string, op *openapi.Operation) *Route {
+	r := *h.RouteInfo
+	r.Method = method
+	r.Path = fullPath
+	r.group = group
+	if op != nil {
+		r.operationID = op.ID
+		r.summary = op.Summary
+		r.description = op.Description
+		r.deprecated = op.Deprecated
+		r.tags = op.Tags
+	}
+	return &r
+}
+
+// Routes returns the routes registered with the
+// handlers of the instance, in registration order.
+func (f *Optizz) Routes() []*Route {
+	f.registry.mu.RLock()
+	defer f.registry.mu.RUnlock()
+
+	return append([]*Route(nil), f.registry.routes...)
+}
+
+// RouteByOperationID returns the route of
+// the operation with the given ID.
+func (f *Optizz) RouteByOperationID(id string) (*Route, error) {
+	f.registry.mu.RLock()
+	defer f.registry.mu.RUnlock()
+
+	if r, ok := f.registry.byID[id]; ok {
+		return r, nil
+	}
+	return nil, errors.New("route not found")
+}
+
+// GetRouteByHandler returns the route information of
+// the given handler, as registered first.
+func (f *Optizz) GetRouteByHandler(h *OptizzHandler) (*Route, error) {
+	f.registry.mu.RLock()
+	defer f.registry.mu.RUnlock()
+
+	if r, ok := f.registry.byHandler[h]; ok {
+		return r, nil
+	}
+	return nil, errors.New("handler is not registered")
+}
+
+// RouteFromContext returns the route that handles the
+// request of the given Fiber context. It can be called
+// from the handlers and from the middlewares of the routes,
+// but also from the middlewares of the app, in which case
+// the route is found with the operation of the specification
+// that matches the method and path of the request.
+func (f *Optizz) RouteFromContext(c *fiber.Ctx) (*Route, error) {
+	f.registry.mu.RLock()
+	cr := c.Route()
+	r, ok := f.registry.byPath[routeKey(cr.Method, cr.Path)]
+	if !ok && cr.Method == fiber.MethodHead {
+		// Fiber registers the GET routes for HEAD too.
+		r, ok = f.registry.byPath[routeKey(fiber.MethodGet, cr.Path)]
+	}
+	f.registry.mu.RUnlock()
+	if ok {
+		return r, nil
+	}
+	op, _ := f.lookupOperation(c.Method(), c.Path())
+	if op == nil && c.Method() == fiber.MethodHead {
+		op, _ = f.lookupOperation(fiber.MethodGet, c.Path())
+	}
+	if op == nil || op.ID == "" {
+		return nil, errors.New("route not found")
+	}
+	return f.RouteByOperationID(op.ID)
+}
@@ -0,0 +1,411 @@
+package optizz
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/wI2L/fizz/openapi"
+)
+
+// schemaValidator validates decoded JSON values against the
+// schemas of an OpenAPI specification. The violations are
+// collected as field errors, designated by the path of the
+// offending value.
+type schemaValidator struct {
+	components *openapi.Components
+	location   string
+	errs       []*FieldError
+
+	// written are the components of the specification loaded
+	// with FromSpec, as written, that the written schemas
+	// passed along with the typed ones refer to.
+	written map[string]interface{}
+
+	// optionalNulls accepts null for the scalar properties
+	// that are not required, as the JSON encoding of the nil
+	// pointers of the optional fields of Go types. The null
+	// arrays and objects, such as nil slices, are rejected
+	// unless their schema is nullable.
+	optionalNulls bool
+}
+
+// patterns caches the compiled patterns of the schemas.
+var patterns sync.Map
+
+// resolve returns the schema designated by sor,
+// following the references to the components.
+func (sv *schemaValidator) resolve(sor *openapi.SchemaOrRef) *openapi.Schema {
+	for i := 0; sor != nil && i < 32; i++ {
+		if sor.Schema != nil {
+			return sor.Schema
+		}
+		if sor.Reference == nil || sv.components == nil {
+			return nil
+		}
+		sor = sv.components.Schemas[strings.TrimPrefix(sor.Reference.Ref, "#/components/schemas/")]
+	}
+	return nil
+}
+
+func (sv *schemaValidator) fail(path, tag, param, format string, a ...interface{}) {
+	sv.errs = append(sv.errs, &FieldError{
+		Field:    path,
+		Location: sv.location,
+		Tag:      tag,
+		Param:    param,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// resolveWritten returns the schemas that make the schema w, as
+// written in the specification loaded with FromSpec: w, or the
+// component schema it refers to, and the members of its allOf
+// composition, which are merged in the typed schema.
+func (sv *schemaValidator) resolveWritten(w interface{}, depth int) []map[string]interface{} {
+	s := writtenComponent(sv.written, "schemas", w)
+	if s == nil || depth > 32 {
+		return nil
+	}
+	schemas := []map[string]interface{}{s}
+	all, _ := s["allOf"].([]interface{})
+	for _, m := range all {
+		schemas = append(schemas, sv.resolveWritten(m, depth+1)...)
+	}
+	return schemas
+}
+
+// writtenMember returns the subschema of the written schemas
+// ws under the key, or under the name within the key if not
+// empty, such as a property.
+func writtenMember(ws []map[string]interface{}, key, name string) interface{} {
+	for _, s := range ws {
+		v := s[key]
+		if name != "" {
+			m, _ := v.(map[string]interface{})
+			v = m[name]
+		}
+		if _, ok := v.(map[string]interface{}); ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// validate validates the value v, decoded from JSON with
+// numbers as json.Number, against the schema sor, and w,
+// the same schema as written in the specification loaded
+// with FromSpec, if any. The numeric bounds of w are
+// enforced in place of the bounds of sor, which cannot
+// represent those of 0 or that are not integers.
+func (sv *schemaValidator) validate(sor *openapi.SchemaOrRef, w, v interface{}, path string) {
+	s := sv.resolve(sor)
+	if s == nil {
+		return
+	}
+	var ws []map[string]interface{}
+	if w != nil {
+		ws = sv.resolveWritten(w, 0)
+	}
+	if s.AllOf != nil {
+		sv.validate(s.AllOf, nil, v, path)
+	}
+	if v == nil {
+		if !s.Nullable && s.Type != "" {
+			sv.fail(path, "nullable", "", "must not be null")
+		}
+		return
+	}
+	if !sv.validateType(s, v, path) {
+		return
+	}
+	if len(s.Enum) != 0 && !enumContains(s.Enum, v) {
+		sv.fail(path, "enum", enumString(s.Enum), "must be one of [%s]", enumString(s.Enum))
+	}
+	switch val := v.(type) {
+	case string:
+		sv.validateString(s, val, path)
+	case json.Number:
+		sv.validateNumber(s, ws, val, path)
+	case []interface{}:
+		sv.validateArray(s, ws, val, path)
+	case map[string]interface{}:
+		sv.validateObject(s, ws, val, path)
+	}
+}
+
+// validateType returns whether the value v
+// is of the type of the schema s.
+func (sv *schemaValidator) validateType(s *openapi.Schema, v interface{}, path string) bool {
+	var ok bool
+	switch s.Type {
+	case "":
+		return true
+	case "object":
+		_, ok = v.(map[string]interface{})
+	case "array":
+		_, ok = v.([]interface{})
+	case "string":
+		_, ok = v.(string)
+	case "boolean":
+		_, ok = v.(bool)
+	case "number":
+		_, ok = v.(json.Number)
+	case "integer":
+		var n json.Number
+		if n, ok = v.(json.Number); ok {
+			_, err := n.Int64()
+			ok = err == nil
+		}
+	default:
+		return true
+	}
+	if !ok {
+		sv.fail(path, "type", s.Type, "must be of type %s", s.Type)
+	}
+	return ok
+}
+
+func (sv *schemaValidator) validateString(s *openapi.Schema, val, path string) {
+	n := utf8.RuneCountInString(val)
+	if s.MinLength != 0 && n < s.MinLength {
+		sv.fail(path, "minLength", fmt.Sprint(s.MinLength), "must be at least %d characters long", s.MinLength)
+	}
+	if s.MaxLength != 0 && n > s.MaxLength {
+		sv.fail(path, "maxLength", fmt.Sprint(s.MaxLength), "must be at most %d characters long", s.MaxLength)
+	}
+	if s.Pattern != "" {
+		if re := compilePattern(s.Pattern); re != nil && !re.MatchString(val) {
+			sv.fail(path, "pattern", s.Pattern, "must match the pattern %s", s.Pattern)
+		}
+	}
+	if s.Format != "" && !validFormat(s.Format, val) {
+		sv.fail(path, "format", s.Format, "must be a valid %s", s.Format)
+	}
+}
+
+// validateNumber validates a number against the bounds, the
+// multiple and the format of the schema s, or against the
+// bounds and the multiple of the written schemas ws, if any.
+// The integer bounds of openapi.Schema cannot tell a bound
+// of 0 from an absent one, so a minimum or maximum of 0 is
+// not enforced by the generated schemas: it is left to the
+// validate tags of the Go types, such as min=0, which the
+// bindings and the response validation check.
+func (sv *schemaValidator) validateNumber(s *openapi.Schema, ws []map[string]interface{}, val json.Number, path string) {
+	f, err := val.Float64()
+	if err != nil {
+		return
+	}
+	if ws != nil {
+		sv.validateWrittenBounds(ws, f, path)
+	} else {
+		sv.validateBounds(s, f, path)
+	}
+	switch s.Format {
+	case "int32":
+		if i, err := val.Int64(); err == nil && (i < math.MinInt32 || i > math.MaxInt32) {
+			sv.fail(path, "format", s.Format, "must be a valid %s", s.Format)
+		}
+	}
+}
+
+func (sv *schemaValidator) validateBounds(s *openapi.Schema, f float64, path string) {
+	if s.Minimum != 0 {
+		if min := float64(s.Minimum); f < min || (s.ExclusiveMinimum && f == min) {
+			sv.fail(path, "minimum", fmt.Sprint(s.Minimum), "must be greater than %s%d", orEqual(!s.ExclusiveMinimum), s.Minimum)
+		}
+	}
+	if s.Maximum != 0 {
+		if max := float64(s.Maximum); f > max || (s.ExclusiveMaximum && f == max) {
+			sv.fail(path, "maximum", fmt.Sprint(s.Maximum), "must be less than %s%d", orEqual(!s.ExclusiveMaximum), s.Maximum)
+		}
+	}
+	if s.MultipleOf != 0 && math.Mod(f, float64(s.MultipleOf)) != 0 {
+		sv.fail(path, "multipleOf", fmt.Sprint(s.MultipleOf), "must be a multiple of %d", s.MultipleOf)
+	}
+}
+
+// validateWrittenBounds validates a number against the
+// bounds and the multiple of the written schemas ws.
+func (sv *schemaValidator) validateWrittenBounds(ws []map[string]interface{}, f float64, path string) {
+	for _, s := range ws {
+		if min, ok := specNumber(s["minimum"]); ok {
+			excl, _ := s["exclusiveMinimum"].(bool)
+			if f < min || (excl && f == min) {
+				sv.fail(path, "minimum", fmt.Sprint(min), "must be greater than %s%v", orEqual(!excl), min)
+			}
+		}
+		if max, ok := specNumber(s["maximum"]); ok {
+			excl, _ := s["exclusiveMaximum"].(bool)
+			if f > max || (excl && f == max) {
+				sv.fail(path, "maximum", fmt.Sprint(max), "must be less than %s%v", orEqual(!excl), max)
+			}
+		}
+		if m, ok := specNumber(s["multipleOf"]); ok && m > 0 {
+			if q := f / m; math.Abs(q-math.Round(q)) > 1e-9 {
+				sv.fail(path, "multipleOf", fmt.Sprint(m), "must be a multiple of %v", m)
+			}
+		}
+	}
+}
+
+// specNumber returns the number v of a specification
+// decoded from JSON or YAML.
+func specNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func (sv *schemaValidator) validateArray(s *openapi.Schema, ws []map[string]interface{}, val []interface{}, path string) {
+	if s.MinItems != 0 && len(val) < s.MinItems {
+		sv.fail(path, "minItems", fmt.Sprint(s.MinItems), "must contain at least %d items", s.MinItems)
+	}
+	if s.MaxItems != 0 && len(val) > s.MaxItems {
+		sv.fail(path, "maxItems", fmt.Sprint(s.MaxItems), "must contain at most %d items", s.MaxItems)
+	}
+	if s.UniqueItems {
+	unique:
+		for i := range val {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(val[i], val[j]) {
+					sv.fail(path, "uniqueItems", "", "must contain unique items")
+					break unique
+				}
+			}
+		}
+	}
+	for i, e := range val {
+		sv.validate(s.Items, writtenMember(ws, "items", ""), e, fmt.Sprintf("%s[%d]", path, i))
+	}
+}
+
+func (sv *schemaValidator) validateObject(s *openapi.Schema, ws []map[string]interface{}, val map[string]interface{}, path string) {
+	for _, r := range s.Required {
+		if _, ok := val[r]; !ok {
+			sv.fail(joinPath(path, r), "required", "", "is required")
+		}
+	}
+	if s.MinProperties != 0 && len(val) < s.MinProperties {
+		sv.fail(path, "minProperties", fmt.Sprint(s.MinProperties), "must have at least %d properties", s.MinProperties)
+	}
+	if s.MaxProperties != 0 && len(val) > s.MaxProperties {
+		sv.fail(path, "maxProperties", fmt.Sprint(s.MaxProperties), "must have at most %d properties", s.MaxProperties)
+	}
+	keys := make([]string, 0, len(val))
+	for k := range val {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if p, ok := s.Properties[k]; ok {
+			if val[k] == nil && sv.optionalNulls && !contains(s.Required, k) {
+				if ps := sv.resolve(p); ps == nil || ps.Type != "array" && ps.Type != "object" {
+					continue
+				}
+			}
+			sv.validate(p, writtenMember(ws, "properties", k), val[k], joinPath(path, k))
+		} else if s.AdditionalProperties != nil {
+			sv.validate(s.AdditionalProperties, writtenMember(ws, "additionalProperties", ""), val[k], joinPath(path, k))
+		}
+	}
+}
+
+// joinPath returns the path of the property
+// with the given name of the object at path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func orEqual(b bool) string {
+	if b {
+		return "or equal to "
+	}
+	return ""
+}
+
+// enumContains returns whether the enum values contain
+// v, compared with their string representation since the
+// enums of the generated schemas are strings.
+func enumContains(enum []interface{}, v interface{}) bool {
+	s := fmt.Sprint(v)
+	for _, e := range enum {
+		if fmt.Sprint(e) == s {
+			return true
+		}
+	}
+	return false
+}
+
+func enumString(enum []interface{}) string {
+	values := make([]string, 0, len(enum))
+	for _, e := range enum {
+		values = append(values, fmt.Sprint(e))
+	}
+	return strings.Join(values, " ")
+}
+
+// compilePattern returns the compiled pattern,
+// or nil if it isn't a valid regular expression.
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	patterns.Store(pattern, re)
+	return re
+}
+
+// validFormat returns whether the string s is valid for
+// the format. Unknown formats accept any value.
+func validFormat(format, s string) bool {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	case "date":
+		_, err = time.Parse("2006-01-02", s)
+	case "uuid":
+		_, err = uuid.Parse(s)
+	case "byte":
+		_, err = base64.StdEncoding.DecodeString(s)
+	case "uri", "url":
+		var u *url.URL
+		if u, err = url.Parse(s); err == nil && !u.IsAbs() {
+			return false
+		}
+	case "ipv4":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() != nil
+	case "ipv6":
+		ip := net.ParseIP(s)
+		return ip != nil && ip.To4() == nil
+	case "email":
+		at := strings.LastIndexByte(s, '@')
+		return at > 0 && at < len(s)-1
+	}
+	return err == nil
+}
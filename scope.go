@@ -0,0 +1,241 @@
+package optizz
+
+import (
+	"log"
+
+	"github.com/gofiber/fiber/v2"
+	validator "gopkg.in/go-playground/validator.v9"
+)
+
+// Hooks represents the set of hooks used by the handlers
+// of an Optizz instance or a router group. A nil hook
+// is inherited from the parent group, and ultimately
+// from the process-wide defaults set with SetErrorHook,
+// SetBindHook and friends.
+type Hooks struct {
+	Error      ErrorHook
+	Bind       BindHook
+	BindQuery  BindHook
+	BindPath   BindHook
+	BindHeader BindHook
+	BindCookie BindHook
+	Render     RenderHook
+	Exec       ExecHook
+}
+
+// scope holds the configuration of the handlers registered
+// on an Optizz instance or a router group. A scope inherits
+// the configuration of its parent. The lookups are lazy so
+// that changes made to a parent after a group was created
+// are visible to the group. A nil scope uses the
+// process-wide defaults.
+type scope struct {
+	parent     *scope
+	hooks      Hooks
+	validator  *validator.Validate
+	strictJSON *bool
+	responses  *ResponseValidation
+	logger     Logger
+}
+
+// newScope returns a new scope that inherits
+// from the given parent.
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent}
+}
+
+// setHooks overrides the hooks of the scope
+// with the non-nil hooks of h.
+func (s *scope) setHooks(h Hooks) {
+	if h.Error != nil {
+		s.hooks.Error = h.Error
+	}
+	if h.Bind != nil {
+		s.hooks.Bind = h.Bind
+	}
+	if h.BindQuery != nil {
+		s.hooks.BindQuery = h.BindQuery
+	}
+	if h.BindPath != nil {
+		s.hooks.BindPath = h.BindPath
+	}
+	if h.BindHeader != nil {
+		s.hooks.BindHeader = h.BindHeader
+	}
+	if h.BindCookie != nil {
+		s.hooks.BindCookie = h.BindCookie
+	}
+	if h.Render != nil {
+		s.hooks.Render = h.Render
+	}
+	if h.Exec != nil {
+		s.hooks.Exec = h.Exec
+	}
+}
+
+// resolvedHooks returns the hooks in use by the scope.
+func (s *scope) resolvedHooks() Hooks {
+	return Hooks{
+		Error:      s.errorHook(),
+		Bind:       s.bindHook(),
+		BindQuery:  s.bindQueryHook(),
+		BindPath:   s.bindPathHook(),
+		BindHeader: s.bindHeaderHook(),
+		BindCookie: s.bindCookieHook(),
+		Render:     s.renderHook(),
+		Exec:       s.execHook(),
+	}
+}
+
+func (s *scope) errorHook() ErrorHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.Error != nil {
+			return s.hooks.Error
+		}
+	}
+	return errorHook
+}
+
+func (s *scope) bindHook() BindHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.Bind != nil {
+			return s.hooks.Bind
+		}
+	}
+	return bindHook
+}
+
+func (s *scope) bindQueryHook() BindHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.BindQuery != nil {
+			return s.hooks.BindQuery
+		}
+	}
+	return bindQueryHook
+}
+
+func (s *scope) bindPathHook() BindHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.BindPath != nil {
+			return s.hooks.BindPath
+		}
+	}
+	return bindPathHook
+}
+
+func (s *scope) bindHeaderHook() BindHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.BindHeader != nil {
+			return s.hooks.BindHeader
+		}
+	}
+	return bindHeaderHook
+}
+
+func (s *scope) bindCookieHook() BindHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.BindCookie != nil {
+			return s.hooks.BindCookie
+		}
+	}
+	return bindCookieHook
+}
+
+func (s *scope) renderHook() RenderHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.Render != nil {
+			return s.hooks.Render
+		}
+	}
+	return renderHook
+}
+
+func (s *scope) execHook() ExecHook {
+	for ; s != nil; s = s.parent {
+		if s.hooks.Exec != nil {
+			return s.hooks.Exec
+		}
+	}
+	return execHook
+}
+
+// validate returns the validator used to validate
+// the input of the handlers.
+func (s *scope) validate() *validator.Validate {
+	for ; s != nil; s = s.parent {
+		if s.validator != nil {
+			return s.validator
+		}
+	}
+	initValidator()
+	return validatorObj
+}
+
+// strict returns whether the JSON bodies
+// are decoded in strict mode.
+func (s *scope) strict() bool {
+	for ; s != nil; s = s.parent {
+		if s.strictJSON != nil {
+			return *s.strictJSON
+		}
+	}
+	return false
+}
+
+// responseValidation returns the mode of the
+// validation of the responses.
+func (s *scope) responseValidation() ResponseValidation {
+	for ; s != nil; s = s.parent {
+		if s.responses != nil {
+			return *s.responses
+		}
+	}
+	return ResponseValidationOff
+}
+
+// log returns the logger of the scope, which
+// defaults to the standard logger.
+func (s *scope) log() Logger {
+	for ; s != nil; s = s.parent {
+		if s.logger != nil {
+			return s.logger
+		}
+	}
+	return log.Default()
+}
+
+// handler returns the Fiber handler that executes the
+// given optizz handler with the configuration of the scope,
+// through the execution hook. The operation of the route
+// is stored in the context.
+func (s *scope) handler(h *OptizzHandler, spec *operationSpec) fiber.Handler {
+	serve := func(c *fiber.Ctx) error {
+		c.Locals(ctxOpenAPIOperation, spec.op)
+		return h.serve(c, s, spec)
+	}
+	return func(c *fiber.Ctx) error {
+		return s.execHook()(c, serve, h.name)
+	}
+}
+
+// handleError handles any error raised during the execution
+// of the wrapping Fiber-handler.
+func (s *scope) handleError(c *fiber.Ctx, err error) {
+	var errors []error
+	_errs := c.Locals("_errors_")
+	if _errs == nil {
+		errors = make([]error, 0)
+	} else {
+		if _errors, ok := _errs.([]error); ok {
+			errors = _errors
+		} else {
+			errors = make([]error, 0)
+		}
+	}
+
+	errors = append(errors, err)
+	c.Locals("_errors_", errors)
+
+	code, resp := s.errorHook()(c, err)
+	s.renderHook()(c, code, resp)
+}
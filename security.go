@@ -0,0 +1,231 @@
+package optizz
+
+import (
+	"strings"
+
+	"github.com/wI2L/fizz/openapi"
+)
+
+// Types of security schemes.
+const (
+	APIKeySecurity        = "apiKey"
+	HTTPSecurity          = "http"
+	OAuth2Security        = "oauth2"
+	OpenIDConnectSecurity = "openIdConnect"
+)
+
+// SecurityScheme represents a security scheme
+// of the OpenAPI specification.
+type SecurityScheme struct {
+	Type             string      `json:"type" yaml:"type"`
+	Description      string      `json:"description,omitempty" yaml:"description,omitempty"`
+	Name             string      `json:"name,omitempty" yaml:"name,omitempty"`
+	In               string      `json:"in,omitempty" yaml:"in,omitempty"`
+	Scheme           string      `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+	BearerFormat     string      `json:"bearerFormat,omitempty" yaml:"bearerFormat,omitempty"`
+	Flows            *OAuthFlows `json:"flows,omitempty" yaml:"flows,omitempty"`
+	OpenIDConnectURL string      `json:"openIdConnectUrl,omitempty" yaml:"openIdConnectUrl,omitempty"`
+}
+
+// OAuthFlows represents the OAuth flows
+// supported by an oauth2 security scheme.
+type OAuthFlows struct {
+	Implicit          *OAuthFlow `json:"implicit,omitempty" yaml:"implicit,omitempty"`
+	Password          *OAuthFlow `json:"password,omitempty" yaml:"password,omitempty"`
+	ClientCredentials *OAuthFlow `json:"clientCredentials,omitempty" yaml:"clientCredentials,omitempty"`
+	AuthorizationCode *OAuthFlow `json:"authorizationCode,omitempty" yaml:"authorizationCode,omitempty"`
+}
+
+// OAuthFlow represents the configuration
+// of an OAuth flow.
+type OAuthFlow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty" yaml:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty" yaml:"tokenUrl,omitempty"`
+	RefreshURL       string            `json:"refreshUrl,omitempty" yaml:"refreshUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes" yaml:"scopes"`
+}
+
+// SecurityRequirement maps the names of security schemes
+// to the scopes they require, for the oauth2 and
+// openIdConnect schemes. All the schemes of a
+// requirement must be satisfied.
+type SecurityRequirement map[string][]string
+
+// APIKeyScheme returns a security scheme for an API
+// key passed as the query parameter, header or
+// cookie with the given name.
+func APIKeyScheme(in, name string) *SecurityScheme {
+	return &SecurityScheme{Type: APIKeySecurity, In: in, Name: name}
+}
+
+// BearerScheme returns a security scheme for a bearer
+// token with the given format, such as JWT.
+func BearerScheme(format string) *SecurityScheme {
+	return &SecurityScheme{Type: HTTPSecurity, Scheme: "bearer", BearerFormat: format}
+}
+
+// BasicScheme returns a security scheme for
+// the HTTP basic authentication.
+func BasicScheme() *SecurityScheme {
+	return &SecurityScheme{Type: HTTPSecurity, Scheme: "basic"}
+}
+
+// OAuth2Scheme returns a security scheme
+// for the given OAuth flows.
+func OAuth2Scheme(flows *OAuthFlows) *SecurityScheme {
+	return &SecurityScheme{Type: OAuth2Security, Flows: flows}
+}
+
+// OpenIDConnectScheme returns a security scheme for the
+// OpenID Connect discovery document at url.
+func OpenIDConnectScheme(url string) *SecurityScheme {
+	return &SecurityScheme{Type: OpenIDConnectSecurity, OpenIDConnectURL: url}
+}
+
+// SecurityScheme adds the security scheme with the given
+// name to the components of the specification, replacing
+// the existing one, if any.
+func (f *Optizz) SecurityScheme(name string, scheme *SecurityScheme) {
+	if name == "" || scheme == nil {
+		return
+	}
+	f.specExt.securitySchemes[name] = scheme
+	f.spec.invalidate()
+}
+
+// SecuritySchemes returns the security schemes
+// of the specification by name.
+func (f *Optizz) SecuritySchemes() map[string]*SecurityScheme {
+	schemes := make(map[string]*SecurityScheme, len(f.specExt.securitySchemes))
+	for name, s := range f.specExt.securitySchemes {
+		schemes[name] = s
+	}
+	return schemes
+}
+
+// Security sets the security requirements of the operation,
+// in place of the requirements of its group. The operation
+// requires one of the requirements, and is public if
+// there is none.
+func Security(reqs ...SecurityRequirement) func(*openapi.OperationInfo) {
+	return func(o *openapi.OperationInfo) {
+		extOf(o).security = securityRequirements(reqs)
+	}
+}
+
+// Public marks the operation as public, regardless
+// of the security requirements of its group.
+func Public() func(*openapi.OperationInfo) {
+	return Security()
+}
+
+// SetSecurity sets the security requirements of the operations
+// of the group, which are inherited by the sub-groups. The
+// operations require one of the requirements, and are
+// public if there is none. They apply to the operations
+// registered before too.
+func (g *RouterGroup) SetSecurity(reqs ...SecurityRequirement) {
+	g.security = securityRequirements(reqs)
+	g.root.documentSecurity()
+}
+
+// securityRequirements returns a copy of the requirements,
+// which is not nil, with empty lists of scopes in place
+// of the nil ones, that would be documented as null.
+func securityRequirements(reqs []SecurityRequirement) []SecurityRequirement {
+	copied := make([]SecurityRequirement, 0, len(reqs))
+	for _, req := range reqs {
+		r := make(SecurityRequirement, len(req))
+		for name, scopes := range req {
+			if scopes == nil {
+				scopes = []string{}
+			}
+			r[name] = scopes
+		}
+		copied = append(copied, r)
+	}
+	return copied
+}
+
+// operationSecurity returns the security requirements of an
+// operation of the group with the extended options ext,
+// or nil if none is declared.
+func (g *RouterGroup) operationSecurity(ext *operationExt) []SecurityRequirement {
+	if ext.security != nil {
+		return ext.security
+	}
+	for grp := g; grp != nil; grp = grp.parent {
+		if grp.security != nil {
+			return grp.security
+		}
+	}
+	return nil
+}
+
+// securityOf returns a func that resolves the security
+// requirements of an operation of the group with the
+// extended options ext, when it is called.
+func (g *RouterGroup) securityOf(ext *operationExt) func() []SecurityRequirement {
+	return func() []SecurityRequirement {
+		return g.operationSecurity(ext)
+	}
+}
+
+// groupOperation is an operation registered
+// with the handler of a router group.
+type groupOperation struct {
+	group *RouterGroup
+	ext   *operationExt
+	op    *openapi.Operation
+	key   operationKey
+}
+
+// documentSecurity documents again the security requirements
+// of the operations registered with the groups, after the
+// requirements of a group changed.
+func (f *Optizz) documentSecurity() {
+	api := f.gen.API()
+	for _, o := range f.groupOps {
+		key, ok := operationKeyOf(api, o.op)
+		if !ok {
+			continue
+		}
+		if reqs := o.group.operationSecurity(o.ext); reqs != nil {
+			f.specExt.security[key] = reqs
+		} else {
+			delete(f.specExt.security, key)
+		}
+	}
+	f.spec.invalidate()
+}
+
+// operationKey designates an operation of the
+// specification by its path and lowercased method.
+type operationKey struct {
+	path   string
+	method string
+}
+
+// operationKeyOf returns the key of the
+// operation op of the specification api.
+func operationKeyOf(api *openapi.OpenAPI, op *openapi.Operation) (operationKey, bool) {
+	for path, item := range api.Paths {
+		if item == nil {
+			continue
+		}
+		for _, method := range pathMethods {
+			if pathOperation(item, method) == op {
+				return operationKey{path: path, method: strings.ToLower(method)}, true
+			}
+		}
+	}
+	return operationKey{}, false
+}
+
+// setSecurity documents the security
+// requirements of the operation op.
+func (se *specExtensions) setSecurity(api *openapi.OpenAPI, op *openapi.Operation, reqs []SecurityRequirement) {
+	if key, ok := operationKeyOf(api, op); ok {
+		se.security[key] = reqs
+	}
+}
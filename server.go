@@ -0,0 +1,131 @@
+package optizz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/wI2L/fizz/openapi"
+)
+
+// AddServer adds a server to the specification. The variables
+// of the URL, such as {region} in {region}.api.example.com,
+// must be described by vars, with a default value.
+func (f *Optizz) AddServer(url, description string, vars map[string]*openapi.ServerVariable) error {
+	s, err := newServer(url, description, vars)
+	if err != nil {
+		return err
+	}
+	f.gen.SetServers(append(f.gen.API().Servers, s))
+	f.spec.invalidate()
+
+	return nil
+}
+
+// AddServer adds a server to the operations of the group, in
+// place of the servers of the specification, for the groups
+// served by a different host. The servers are inherited by
+// the sub-groups, and documented on the paths of the
+// operations, or on the operations themselves if those of
+// a path have different servers. They apply to the
+// operations registered before too.
+func (g *RouterGroup) AddServer(url, description string, vars map[string]*openapi.ServerVariable) error {
+	s, err := newServer(url, description, vars)
+	if err != nil {
+		return err
+	}
+	g.servers = append(g.operationServers(), s)
+	g.root.documentServers()
+
+	return nil
+}
+
+// operationServers returns the servers of the operations
+// of the group, inherited from its parent groups if
+// it has none.
+func (g *RouterGroup) operationServers() []*openapi.Server {
+	for grp := g; grp != nil; grp = grp.parent {
+		if grp.servers != nil {
+			return append([]*openapi.Server(nil), grp.servers...)
+		}
+	}
+	return nil
+}
+
+// documentServers documents again the servers of the groups on
+// the paths of their operations, or the given paths only. The
+// servers of the operations of a path that differ, or of a
+// path that has operations registered without a group, are
+// documented on the operations.
+func (f *Optizz) documentServers(paths ...string) {
+	api := f.gen.API()
+	byPath := make(map[string][]*groupOperation)
+	for _, o := range f.groupOps {
+		if len(paths) == 0 || contains(paths, o.key.path) {
+			byPath[o.key.path] = append(byPath[o.key.path], o)
+		}
+	}
+	for path, ops := range byPath {
+		item := api.Paths[path]
+		if item == nil {
+			continue
+		}
+		// The servers of a path item that has other operations,
+		// such as those of a specification loaded with FromSpec,
+		// are left as is.
+		owned := len(ops) == len(pathOperations(item))
+		servers := ops[0].group.operationServers()
+		shared := owned
+		for _, o := range ops[1:] {
+			shared = shared && equalJSON(o.group.operationServers(), servers)
+		}
+		if shared {
+			item.Servers = servers
+		} else if owned {
+			item.Servers = nil
+		}
+		for _, o := range ops {
+			o.op.Servers = nil
+			if !shared {
+				o.op.Servers = o.group.operationServers()
+			}
+		}
+	}
+	f.spec.invalidate()
+}
+
+// newServer returns the server with the given URL, after
+// checking that its variables are described.
+func newServer(url, description string, vars map[string]*openapi.ServerVariable) (*openapi.Server, error) {
+	if url == "" {
+		return nil, errors.New("empty server URL")
+	}
+	for rest := url; ; {
+		i := strings.IndexByte(rest, '{')
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(rest[i:], '}')
+		if j < 0 {
+			return nil, fmt.Errorf("server URL %s: unterminated variable", url)
+		}
+		name := rest[i+1 : i+j]
+		if _, ok := vars[name]; !ok {
+			return nil, fmt.Errorf("server URL %s: variable %q is not described", url, name)
+		}
+		rest = rest[i+j+1:]
+	}
+	for name, v := range vars {
+		if v == nil || v.Default == "" {
+			return nil, fmt.Errorf("server URL %s: variable %q has no default value", url, name)
+		}
+		if len(v.Enum) != 0 && !contains(v.Enum, v.Default) {
+			return nil, fmt.Errorf("server URL %s: the default value of variable %q is not in its enum", url, name)
+		}
+	}
+	return &openapi.Server{
+		URL:         url,
+		Description: description,
+		Variables:   vars,
+	}, nil
+}
@@ -0,0 +1,958 @@
+package optizz
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/wI2L/fizz/openapi"
+	"gopkg.in/yaml.v2"
+)
+
+// specDocument represents a marshalled OpenAPI
+// specification and its entity tag.
+type specDocument struct {
+	body []byte
+	etag string
+}
+
+// specCache caches the marshalled OpenAPI specification
+// of an Optizz instance for each format. It is invalidated
+// every time the specification changes.
+type specCache struct {
+	mu       sync.Mutex
+	docs     map[string]*specDocument
+	modified time.Time
+	gen      uint64
+}
+
+func newSpecCache() *specCache {
+	sc := &specCache{}
+	sc.invalidate()
+	return sc
+}
+
+// invalidate drops the cached documents and
+// updates the last modification time.
+func (sc *specCache) invalidate() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.docs = make(map[string]*specDocument)
+	sc.modified = time.Now().UTC().Truncate(time.Second)
+	sc.gen++
+}
+
+// version returns a number that changes
+// every time the specification changes.
+func (sc *specCache) version() uint64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.gen
+}
+
+// get returns the cached document for the given format,
+// and marshals it with the marshal func if none exists.
+func (sc *specCache) get(format string, marshal func(string) ([]byte, error)) (*specDocument, time.Time, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if doc, ok := sc.docs[format]; ok {
+		return doc, sc.modified, nil
+	}
+	b, err := marshal(format)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	sum := sha256.Sum256(b)
+	doc := &specDocument{
+		body: b,
+		etag: `"` + hex.EncodeToString(sum[:16]) + `"`,
+	}
+	sc.docs[format] = doc
+
+	return doc, sc.modified, nil
+}
+
+// specExtensions holds the parts of the OpenAPI specification
+// that the types of the generator cannot represent. They are
+// merged into the document when it is marshalled.
+type specExtensions struct {
+	// closedSchemas are the names of the component schemas
+	// that do not allow additional properties, including
+	// their inline object sub-schemas if the value is true.
+	closedSchemas map[string]bool
+
+	// strictSchemas are the names of the closed copies of
+	// the component schemas the request bodies decoded in
+	// strict mode refer to, by the name of the schemas.
+	strictSchemas map[string]string
+
+	// tagGroups are the groups of the x-tagGroups
+	// extension, one per top-level router group
+	// that has nested groups.
+	tagGroups []*tagGroup
+
+	// securitySchemes are the security schemes of the
+	// components, and security the security requirements
+	// of the operations.
+	securitySchemes map[string]*SecurityScheme
+	security        map[operationKey][]SecurityRequirement
+
+	// contractPaths and contractComponents are the path items
+	// and the components of the specification loaded with
+	// FromSpec, as written, which are served in place of
+	// their normalized representation.
+	contractPaths      map[string]interface{}
+	contractComponents map[string]interface{}
+}
+
+// tagGroup is a group of tags of
+// the x-tagGroups extension.
+type tagGroup struct {
+	name string
+	tags []string
+}
+
+func newSpecExtensions() *specExtensions {
+	return &specExtensions{
+		closedSchemas:   make(map[string]bool),
+		strictSchemas:   make(map[string]string),
+		securitySchemes: make(map[string]*SecurityScheme),
+		security:        make(map[operationKey][]SecurityRequirement),
+	}
+}
+
+func (se *specExtensions) empty() bool {
+	return len(se.closedSchemas) == 0 && len(se.tagGroups) == 0 &&
+		len(se.securitySchemes) == 0 && len(se.security) == 0 &&
+		len(se.contractPaths) == 0 && len(se.contractComponents) == 0
+}
+
+// addTagGroup adds the tag to the tag group with
+// the given name, which contains its own tag.
+func (se *specExtensions) addTagGroup(name, tag string) {
+	var tg *tagGroup
+	for _, g := range se.tagGroups {
+		if g.name == name {
+			tg = g
+		}
+	}
+	if tg == nil {
+		tg = &tagGroup{name: name, tags: []string{name}}
+		se.tagGroups = append(se.tagGroups, tg)
+	}
+	if !contains(tg.tags, tag) {
+		tg.tags = append(tg.tags, tag)
+	}
+}
+
+// apply merges the extensions into the document doc,
+// which is the generic representation of the
+// specification decoded from JSON.
+func (se *specExtensions) apply(doc map[string]interface{}) {
+	se.applyContract(doc)
+
+	components, _ := doc["components"].(map[string]interface{})
+	schemas, _ := components["schemas"].(map[string]interface{})
+	for name, deep := range se.closedSchemas {
+		if schema, ok := schemas[name].(map[string]interface{}); ok {
+			schema["additionalProperties"] = false
+			if deep {
+				closeInlineSchemas(schema, 0)
+			}
+		}
+	}
+	if len(se.tagGroups) != 0 {
+		doc["x-tagGroups"] = se.tagGroupsOf(doc)
+	}
+	if len(se.securitySchemes) != 0 {
+		if components == nil {
+			components = make(map[string]interface{})
+			doc["components"] = components
+		}
+		components["securitySchemes"] = se.securitySchemes
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for key, reqs := range se.security {
+		item, _ := paths[key.path].(map[string]interface{})
+		if op, ok := item[key.method].(map[string]interface{}); ok {
+			op["security"] = reqs
+		}
+	}
+}
+
+// applyContract replaces the path items and the components
+// of the contract in the document doc with copies of their
+// definitions as written. The operations added to the
+// paths of the contract with Handle are kept.
+func (se *specExtensions) applyContract(doc map[string]interface{}) {
+	if len(se.contractComponents) != 0 {
+		components, _ := doc["components"].(map[string]interface{})
+		if components == nil {
+			components = make(map[string]interface{})
+			doc["components"] = components
+		}
+		for kind, v := range se.contractComponents {
+			src, ok := v.(map[string]interface{})
+			if !ok || kind == "securitySchemes" {
+				continue
+			}
+			dst, _ := components[kind].(map[string]interface{})
+			if dst == nil {
+				dst = make(map[string]interface{}, len(src))
+				components[kind] = dst
+			}
+			for name, c := range src {
+				dst[name] = copyValue(c)
+			}
+		}
+	}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for path, v := range se.contractPaths {
+		src, _ := v.(map[string]interface{})
+		dst, ok := paths[path].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, e := range src {
+			dst[k] = copyValue(e)
+		}
+	}
+}
+
+// copyValue returns a deep copy of the
+// generic value v decoded from JSON.
+func copyValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(val))
+		for k, e := range val {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			s[i] = copyValue(e)
+		}
+		return s
+	}
+	return v
+}
+
+// closeInlineSchemas documents the inline object sub-schemas
+// of the generic schema s as not allowing additional
+// properties. The references are closed on their own.
+func closeInlineSchemas(s map[string]interface{}, depth int) {
+	if depth > 64 {
+		return
+	}
+	var subs []interface{}
+	if props, ok := s["properties"].(map[string]interface{}); ok {
+		for _, p := range props {
+			subs = append(subs, p)
+		}
+	}
+	for _, k := range []string{"items", "additionalProperties", "allOf", "oneOf", "anyOf"} {
+		subs = append(subs, s[k])
+	}
+	for _, sub := range subs {
+		m, ok := sub.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := m["$ref"]; ok {
+			continue
+		}
+		if _, ok := m["properties"]; ok && m["type"] == "object" {
+			if _, ok := m["additionalProperties"]; !ok {
+				m["additionalProperties"] = false
+			}
+		}
+		closeInlineSchemas(m, depth+1)
+	}
+}
+
+// tagGroupsOf returns the x-tagGroups extension of the
+// document doc. The tags that are in no group, which
+// ReDoc would hide, are in a group of their own.
+func (se *specExtensions) tagGroupsOf(doc map[string]interface{}) []interface{} {
+	var groups []interface{}
+	grouped := make(map[string]bool)
+
+	for _, tg := range se.tagGroups {
+		groups = append(groups, map[string]interface{}{
+			"name": tg.name,
+			"tags": tg.tags,
+		})
+		for _, t := range tg.tags {
+			grouped[t] = true
+		}
+	}
+	tags, _ := doc["tags"].([]interface{})
+	for _, t := range tags {
+		tag, _ := t.(map[string]interface{})
+		name, _ := tag["name"].(string)
+		if name == "" || grouped[name] {
+			continue
+		}
+		groups = append(groups, map[string]interface{}{
+			"name": name,
+			"tags": []string{name},
+		})
+	}
+	return groups
+}
+
+// marshalSpec marshals the OpenAPI specification
+// of the API in the given format.
+func (f *Optizz) marshalSpec(format string) ([]byte, error) {
+	if f.specExt.empty() {
+		if format == "yaml" {
+			return yaml.Marshal(f.gen.API())
+		}
+		return json.Marshal(f.gen.API())
+	}
+	b, err := json.Marshal(f.gen.API())
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	f.specExt.apply(doc)
+
+	if format == "yaml" {
+		return yaml.Marshal(doc)
+	}
+	return json.Marshal(doc)
+}
+
+// renderSpec writes the OpenAPI specification of
+// the API to the response in the given format.
+// The marshalled specification is cached until the
+// specification changes, and conditional requests
+// are answered with a 304 if it didn't.
+func (f *Optizz) renderSpec(c *fiber.Ctx, format string) error {
+	doc, modified, err := f.spec.get(format, f.marshalSpec)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderETag, doc.etag)
+	c.Set(fiber.HeaderLastModified, modified.Format(http.TimeFormat))
+	c.Vary(fiber.HeaderAccept)
+
+	if notModified(c, doc.etag, modified) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	if format == "yaml" {
+		c.Set(fiber.HeaderContentType, yamlMediaType)
+	} else {
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	return c.Status(200).Send(doc.body)
+}
+
+// notModified returns whether the conditional headers of
+// the request match the given entity tag and modification
+// time. If-None-Match takes precedence over If-Modified-Since.
+func notModified(c *fiber.Ctx, etag string, modified time.Time) bool {
+	if inm := c.Get(fiber.HeaderIfNoneMatch); inm != "" {
+		for _, t := range strings.Split(inm, ",") {
+			t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+			if t == "*" || t == etag {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := c.Get(fiber.HeaderIfModifiedSince); ims != "" {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modified.After(t)
+	}
+	return false
+}
+
+// setParameterStyles sets the style of the query parameters
+// of the operation that are bound from the fields of the
+// input type t with the deepObject style.
+func setParameterStyles(op *openapi.Operation, t reflect.Type) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			setParameterStyles(op, sf.Type)
+			continue
+		}
+		tag, ok := sf.Tag.Lookup(QueryTag)
+		if !ok {
+			continue
+		}
+		name, _, _, style, err := parseTagKey(tag)
+		if err != nil || paramStyle(sf, style) != DeepObjectStyle {
+			continue
+		}
+		for _, p := range op.Parameters {
+			if p.Parameter != nil && p.In == QueryTag && p.Name == name {
+				p.Style = DeepObjectStyle
+				p.Explode = true
+			}
+		}
+	}
+}
+
+// specInputType returns the input type t as seen by the
+// generator, which rejects the style option of the location
+// tags: a copy of the type where the option is removed,
+// or t itself if no tag has one. The generator ignores
+// the unexported fields, which the copy omits, and
+// the fields of embedded structs are flattened.
+func specInputType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	st := t
+	if st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct || !hasStyleOption(st) {
+		return t
+	}
+	st = reflect.StructOf(specFields(st, make(map[string]bool)))
+	if t.Kind() == reflect.Ptr {
+		return reflect.PtrTo(st)
+	}
+	return st
+}
+
+// hasStyleOption returns whether a location tag of a
+// field of the struct type t has the style option.
+func hasStyleOption(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if ft := derefType(sf.Type); sf.Anonymous && ft.Kind() == reflect.Struct {
+			if hasStyleOption(ft) {
+				return true
+			}
+			continue
+		}
+		for _, loc := range paramLocations {
+			if _, _, _, style, err := parseTagKey(sf.Tag.Get(loc)); err == nil && style != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// specFields returns the fields of the copy of the struct
+// type t seen by the generator, without the style option.
+// The names already used by the copy are skipped.
+func specFields(t reflect.Type, seen map[string]bool) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			if ft := derefType(sf.Type); ft.Kind() == reflect.Struct {
+				fields = append(fields, specFields(ft, seen)...)
+			}
+			continue
+		}
+		if sf.PkgPath != "" || seen[sf.Name] {
+			continue
+		}
+		seen[sf.Name] = true
+
+		tag := string(sf.Tag)
+		for _, loc := range paramLocations {
+			v, ok := sf.Tag.Lookup(loc)
+			if !ok {
+				continue
+			}
+			var opts []string
+			for _, o := range strings.Split(v, ",") {
+				if !strings.HasPrefix(strings.TrimSpace(o), StyleTag+"=") {
+					opts = append(opts, o)
+				}
+			}
+			tag = strings.Replace(tag, loc+":"+strconv.Quote(v), loc+":"+strconv.Quote(strings.Join(opts, ",")), 1)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: sf.Name,
+			Type: sf.Type,
+			Tag:  reflect.StructTag(tag),
+		})
+	}
+	return fields
+}
+
+// derefType returns the type t, or the
+// type it points to if it is a pointer.
+func derefType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// setCookieParams adds the cookie parameters bound from the
+// fields of the input type t to the operation. The generator
+// does not know the cookie location and documents these
+// fields as part of the request body, from which they
+// are removed.
+func setCookieParams(api *openapi.OpenAPI, op *openapi.Operation, t reflect.Type) {
+	addCookieParams(api, op, t, &schemaRefs{api: api})
+}
+
+func addCookieParams(api *openapi.OpenAPI, op *openapi.Operation, t reflect.Type, refs *schemaRefs) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			addCookieParams(api, op, sf.Type, refs)
+			continue
+		}
+		tag, ok := sf.Tag.Lookup(CookieTag)
+		if !ok {
+			continue
+		}
+		name, err := ParseTagKey(tag)
+		if err != nil {
+			continue
+		}
+		removeBodyProperty(api, op, jsonFieldName(sf), refs)
+
+		deprecated, _ := strconv.ParseBool(sf.Tag.Get("deprecated"))
+		op.Parameters = append(op.Parameters, &openapi.ParameterOrRef{Parameter: &openapi.Parameter{
+			Name:        name,
+			In:          CookieTag,
+			Description: sf.Tag.Get("description"),
+			Required:    isRequired(sf),
+			Deprecated:  deprecated,
+			Schema:      &openapi.SchemaOrRef{Schema: paramSchema(sf)},
+		}})
+	}
+}
+
+// removeBodyProperty removes the property with the given
+// name from the schema of the request body of the operation.
+// The request body is removed if it has no property left,
+// and so is its component schema, unless it is shared.
+func removeBodyProperty(api *openapi.OpenAPI, op *openapi.Operation, name string, refs *schemaRefs) {
+	if op.RequestBody == nil {
+		return
+	}
+	for mt, content := range op.RequestBody.Content {
+		if content.Schema == nil {
+			continue
+		}
+		schema, ref := content.Schema.Schema, ""
+		if content.Schema.Reference != nil {
+			ref = strings.TrimPrefix(content.Schema.Reference.Ref, "#/components/schemas/")
+			if sor, ok := api.Components.Schemas[ref]; ok && sor != nil {
+				schema = sor.Schema
+			}
+			// The schema referenced by other operations
+			// or schemas is left untouched, the operation
+			// uses a copy of it instead.
+			if schema != nil && refs.shared(ref) {
+				refs.release(ref)
+				schema, ref = copySchema(schema), ""
+				content.Schema = &openapi.SchemaOrRef{Schema: schema}
+			}
+		}
+		if schema == nil {
+			continue
+		}
+		delete(schema.Properties, name)
+		for j, r := range schema.Required {
+			if r == name {
+				schema.Required = append(schema.Required[:j], schema.Required[j+1:]...)
+				break
+			}
+		}
+		if len(schema.Properties) == 0 {
+			delete(op.RequestBody.Content, mt)
+			if ref != "" {
+				delete(api.Components.Schemas, ref)
+			}
+		}
+	}
+	if len(op.RequestBody.Content) == 0 {
+		op.RequestBody = nil
+	}
+}
+
+// copySchema returns a copy of the schema s, whose
+// properties and required properties can be changed
+// without changing those of s.
+func copySchema(s *openapi.Schema) *openapi.Schema {
+	cpy := *s
+	cpy.Properties = make(map[string]*openapi.SchemaOrRef, len(s.Properties))
+	for k, v := range s.Properties {
+		cpy.Properties[k] = v
+	}
+	cpy.Required = append([]string(nil), s.Required...)
+	return &cpy
+}
+
+// schemaRefs counts the references to the component
+// schemas of the specification api, which are walked
+// on the first lookup.
+type schemaRefs struct {
+	api    *openapi.OpenAPI
+	counts map[string]int
+}
+
+// shared returns whether the component schema with the
+// given name is referenced more than once.
+func (sr *schemaRefs) shared(name string) bool {
+	if sr.counts == nil {
+		sr.counts = make(map[string]int)
+		sr.walkAPI()
+	}
+	return sr.counts[name] > 1
+}
+
+// release records that a reference to the
+// component schema was replaced.
+func (sr *schemaRefs) release(name string) {
+	sr.counts[name]--
+}
+
+func (sr *schemaRefs) walkAPI() {
+	for _, item := range sr.api.Paths {
+		if item == nil {
+			continue
+		}
+		for _, p := range item.Parameters {
+			if p != nil && p.Parameter != nil {
+				sr.walk(p.Parameter.Schema, 0)
+			}
+		}
+		for _, method := range pathMethods {
+			op := pathOperation(item, method)
+			if op == nil {
+				continue
+			}
+			for _, p := range op.Parameters {
+				if p != nil && p.Parameter != nil {
+					sr.walk(p.Parameter.Schema, 0)
+				}
+			}
+			if op.RequestBody != nil {
+				// The media types of the codecs
+				// share the same content.
+				seen := make(map[*openapi.MediaType]bool)
+				for _, content := range op.RequestBody.Content {
+					if content != nil && !seen[content] {
+						seen[content] = true
+						sr.walk(content.Schema, 0)
+					}
+				}
+			}
+			for _, r := range op.Responses {
+				sr.walkResponse(r)
+			}
+		}
+	}
+	if c := sr.api.Components; c != nil {
+		for _, s := range c.Schemas {
+			sr.walk(s, 0)
+		}
+		for _, r := range c.Responses {
+			sr.walkResponse(r)
+		}
+		for _, p := range c.Parameters {
+			if p != nil && p.Parameter != nil {
+				sr.walk(p.Parameter.Schema, 0)
+			}
+		}
+		for _, h := range c.Headers {
+			if h != nil && h.Header != nil {
+				sr.walk(h.Header.Schema, 0)
+			}
+		}
+	}
+}
+
+func (sr *schemaRefs) walkResponse(r *openapi.ResponseOrRef) {
+	if r == nil || r.Response == nil {
+		return
+	}
+	// The media types of the codecs
+	// share the same content.
+	seen := make(map[*openapi.MediaTypeOrRef]bool)
+	for _, content := range r.Content {
+		if content != nil && content.MediaType != nil && !seen[content] {
+			seen[content] = true
+			sr.walk(content.MediaType.Schema, 0)
+		}
+	}
+	for _, h := range r.Headers {
+		if h != nil && h.Header != nil {
+			sr.walk(h.Header.Schema, 0)
+		}
+	}
+}
+
+// walk counts the references of the schema sor and
+// of its sub-schemas. The references are not
+// followed, the component schemas are walked
+// on their own.
+func (sr *schemaRefs) walk(sor *openapi.SchemaOrRef, depth int) {
+	if sor == nil || depth > 64 {
+		return
+	}
+	if sor.Reference != nil {
+		if name := strings.TrimPrefix(sor.Reference.Ref, "#/components/schemas/"); name != sor.Reference.Ref {
+			sr.counts[name]++
+		}
+		return
+	}
+	s := sor.Schema
+	if s == nil {
+		return
+	}
+	for _, sub := range []*openapi.SchemaOrRef{s.AllOf, s.OneOf, s.AnyOf, s.Items, s.AdditionalProperties} {
+		sr.walk(sub, depth+1)
+	}
+	for _, p := range s.Properties {
+		sr.walk(p, depth+1)
+	}
+}
+
+// isRequired returns whether the struct field
+// is required by its validation tag.
+func isRequired(sf reflect.StructField) bool {
+	for _, o := range strings.Split(sf.Tag.Get(ValidationTag), ",") {
+		if o == "dive" || o == "keys" {
+			return false
+		}
+		if o == "required" {
+			return true
+		}
+	}
+	return false
+}
+
+// paramSchema returns the schema of a parameter bound
+// from the struct field, which is of a primitive type
+// or a slice of primitive types.
+func paramSchema(sf reflect.StructField) *openapi.Schema {
+	t := sf.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schema := &openapi.Schema{}
+	items := schema
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+		items = &openapi.Schema{}
+		schema.Type = "array"
+		schema.Items = &openapi.SchemaOrRef{Schema: items}
+	}
+	dt := openapi.DataTypeFromType(t)
+	items.Type, items.Format = dt.Type(), dt.Format()
+
+	if enum := sf.Tag.Get(EnumTag); enum != "" {
+		for _, v := range strings.Split(enum, ",") {
+			items.Enum = append(items.Enum, v)
+		}
+	}
+	if d := sf.Tag.Get(DefaultTag); d != "" {
+		schema.Default = d
+	}
+	if f, ok := sf.Tag.Lookup("format"); ok {
+		items.Format = f
+	}
+	return schema
+}
+
+// closeRequestBody documents the JSON request body of the
+// operation as not allowing additional properties, for the
+// operations that decode it in strict mode. The strict
+// decoding rejects the unknown fields of the nested objects
+// too, so they are closed as well, along with their inline
+// object sub-schemas. The schema of the body is named after
+// the operation, but the schemas it refers to may be shared
+// with other operations, so the body refers to closed copies
+// of them instead, named after them with a Strict suffix.
+func closeRequestBody(api *openapi.OpenAPI, se *specExtensions, op *openapi.Operation) {
+	if op.RequestBody == nil {
+		return
+	}
+	for mt, content := range op.RequestBody.Content {
+		if !isJSONMediaType(mt) || content.Schema == nil || content.Schema.Reference == nil {
+			continue
+		}
+		name := strings.TrimPrefix(content.Schema.Reference.Ref, "#/components/schemas/")
+		target, ok := api.Components.Schemas[name]
+		if !ok || target == nil || target.Schema == nil || se.closedSchemas[name] {
+			continue
+		}
+		api.Components.Schemas[name] = strictSchema(api, se, target, 0)
+		if isOpenObject(target.Schema) {
+			se.closedSchemas[name] = true
+		}
+	}
+}
+
+// strictSchema returns a copy of the schema sor whose
+// references to the schemas that are closed in strict
+// mode are replaced by references to their closed copies.
+func strictSchema(api *openapi.OpenAPI, se *specExtensions, sor *openapi.SchemaOrRef, depth int) *openapi.SchemaOrRef {
+	if sor == nil || depth > 64 {
+		return sor
+	}
+	if sor.Reference != nil {
+		name := strings.TrimPrefix(sor.Reference.Ref, "#/components/schemas/")
+		if strict, ok := se.strictSchemas[name]; ok {
+			return &openapi.SchemaOrRef{Reference: &openapi.Reference{Ref: "#/components/schemas/" + strict}}
+		}
+		if !refersToObject(api, sor, make(map[string]bool), 0) {
+			return sor
+		}
+		target := api.Components.Schemas[name]
+		strict := name + "Strict"
+		for i := 2; api.Components.Schemas[strict] != nil; i++ {
+			strict = name + "Strict" + strconv.Itoa(i)
+		}
+		// The copy is registered before the sub-schemas
+		// are walked, for the recursive schemas.
+		se.strictSchemas[name] = strict
+		api.Components.Schemas[strict] = target
+		api.Components.Schemas[strict] = strictSchema(api, se, target, depth+1)
+		if isOpenObject(target.Schema) {
+			se.closedSchemas[strict] = true
+		}
+		return &openapi.SchemaOrRef{Reference: &openapi.Reference{Ref: "#/components/schemas/" + strict}}
+	}
+	if sor.Schema == nil {
+		return sor
+	}
+	cpy := *sor.Schema
+	cpy.AllOf = strictSchema(api, se, cpy.AllOf, depth+1)
+	cpy.OneOf = strictSchema(api, se, cpy.OneOf, depth+1)
+	cpy.AnyOf = strictSchema(api, se, cpy.AnyOf, depth+1)
+	cpy.Items = strictSchema(api, se, cpy.Items, depth+1)
+	cpy.AdditionalProperties = strictSchema(api, se, cpy.AdditionalProperties, depth+1)
+	if cpy.Properties != nil {
+		cpy.Properties = make(map[string]*openapi.SchemaOrRef, len(sor.Schema.Properties))
+		for k, p := range sor.Schema.Properties {
+			cpy.Properties[k] = strictSchema(api, se, p, depth+1)
+		}
+	}
+	return &openapi.SchemaOrRef{Schema: &cpy}
+}
+
+// refersToObject returns whether the schema sor or its
+// sub-schemas refer to a component object schema, which
+// must be closed in strict mode.
+func refersToObject(api *openapi.OpenAPI, sor *openapi.SchemaOrRef, seen map[string]bool, depth int) bool {
+	if sor == nil || depth > 64 {
+		return false
+	}
+	if sor.Reference != nil {
+		name := strings.TrimPrefix(sor.Reference.Ref, "#/components/schemas/")
+		if seen[name] {
+			return false
+		}
+		seen[name] = true
+		target, ok := api.Components.Schemas[name]
+		if !ok || target == nil || target.Schema == nil {
+			return false
+		}
+		return isOpenObject(target.Schema) || refersToObject(api, target, seen, depth+1)
+	}
+	s := sor.Schema
+	if s == nil {
+		return false
+	}
+	for _, sub := range []*openapi.SchemaOrRef{s.AllOf, s.OneOf, s.AnyOf, s.Items, s.AdditionalProperties} {
+		if refersToObject(api, sub, seen, depth+1) {
+			return true
+		}
+	}
+	for _, p := range s.Properties {
+		if refersToObject(api, p, seen, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpenObject returns whether the schema s is an object
+// schema that does not document additional properties.
+// The maps document their values as additional properties.
+func isOpenObject(s *openapi.Schema) bool {
+	return s != nil && s.Type == "object" && s.AdditionalProperties == nil
+}
+
+// bodyMediaTypes returns the sorted media
+// types documented for the request body.
+func bodyMediaTypes(rb *openapi.RequestBody) []string {
+	mts := make([]string, 0, len(rb.Content))
+	for mt := range rb.Content {
+		mts = append(mts, mt)
+	}
+	sort.Strings(mts)
+	return mts
+}
+
+// setContentTypes documents the request body and the
+// responses of the operation with the media types it
+// consumes and produces, in place of the JSON content
+// generated by default. The operations that declare
+// none are documented with JSON, and the form of the
+// input types that have form fields.
+func setContentTypes(op *openapi.Operation, consumes, produces []string) {
+	if op.RequestBody != nil && len(consumes) != 0 {
+		if mt, ok := op.RequestBody.Content[JSONMediaType]; ok {
+			delete(op.RequestBody.Content, JSONMediaType)
+			if !contains(consumes, fiber.MIMEApplicationForm) {
+				delete(op.RequestBody.Content, fiber.MIMEApplicationForm)
+			}
+			for _, t := range consumes {
+				if _, ok := op.RequestBody.Content[t]; !ok {
+					op.RequestBody.Content[t] = mt
+				}
+			}
+		}
+	}
+	if len(produces) == 0 {
+		return
+	}
+	for _, r := range op.Responses {
+		if r == nil || r.Response == nil {
+			continue
+		}
+		if mt, ok := r.Content[JSONMediaType]; ok {
+			delete(r.Content, JSONMediaType)
+			for _, t := range produces {
+				r.Content[t] = mt
+			}
+		}
+	}
+}
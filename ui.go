@@ -0,0 +1,148 @@
+package optizz
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"mime"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UIKind represents a documentation UI that can be
+// served by DocsUI.
+type UIKind int
+
+// Documentation UIs.
+const (
+	SwaggerUI UIKind = iota
+)
+
+// uiAssets holds the embedded copy of the UI assets.
+//
+//go:embed ui
+var uiAssets embed.FS
+
+// uiBundles maps a UI kind to the directory of its assets
+// within the embedded filesystem and the name of the
+// script bundle the page loads.
+var uiBundles = map[UIKind]struct{ dir, script string }{
+	SwaggerUI: {"ui/swagger-ui", "swagger-ui-bundle.js"},
+}
+
+// UIOptions represents the options of a documentation UI.
+type UIOptions struct {
+	// Kind is the UI to serve, default to SwaggerUI.
+	Kind UIKind
+	// Title is the title of the HTML page.
+	Title string
+	// SpecURL is the URL of the specification loaded
+	// by the UI. If empty, the specification is served
+	// by the UI itself at <prefix>/openapi.json.
+	SpecURL string
+	// DeepLinking enables deep linking to the tags
+	// and operations of the specification.
+	DeepLinking bool
+	// TryItOut enables the "try it out" feature of the UI.
+	TryItOut bool
+	// Assets overrides the filesystem the UI assets are
+	// served from, such as another version of Swagger UI.
+	// Its root must contain the script bundle of the UI,
+	// which is swagger-ui-bundle.js for Swagger UI.
+	Assets fs.FS
+}
+
+// DocsUI mounts a documentation UI on the Fiber app at the
+// given prefix. The UI is bound to the generated specification
+// and served from an embedded copy of its assets, so it does
+// not need any CDN.
+func (f *Optizz) DocsUI(prefix string, opts UIOptions) {
+	bundle, ok := uiBundles[opts.Kind]
+	if !ok {
+		panic("invalid UI kind, use SwaggerUI")
+	}
+	assets := opts.Assets
+	if assets == nil {
+		sub, err := fs.Sub(uiAssets, bundle.dir)
+		if err != nil {
+			panic(err)
+		}
+		assets = sub
+	}
+	if _, err := fs.Stat(assets, bundle.script); err != nil {
+		panic("missing UI assets: " + bundle.script + " not found in UIOptions.Assets")
+	}
+	prefix = "/" + strings.Trim(prefix, "/")
+
+	if opts.Title == "" {
+		opts.Title = "API Reference"
+	}
+	if opts.SpecURL == "" {
+		opts.SpecURL = joinPaths(prefix, "openapi.json")
+		f.app.Get(opts.SpecURL, func(c *fiber.Ctx) error {
+			return f.renderSpec(c, "json")
+		})
+	}
+	var buf bytes.Buffer
+	err := uiTemplates.ExecuteTemplate(&buf, bundle.script, struct {
+		UIOptions
+		Prefix string
+	}{opts, strings.TrimSuffix(prefix, "/")})
+	if err != nil {
+		panic(err)
+	}
+	page := buf.Bytes()
+
+	index := func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(page)
+	}
+	f.app.Get(prefix, index)
+	f.app.Get(joinPaths(prefix, "*"), func(c *fiber.Ctx) error {
+		name := c.Params("*")
+		if name == "" || name == "index.html" {
+			return index(c)
+		}
+		b, err := fs.ReadFile(assets, name)
+		if err != nil {
+			return fiber.ErrNotFound
+		}
+		if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+			c.Set(fiber.HeaderContentType, ct)
+		}
+		return c.Send(b)
+	})
+}
+
+// uiTemplates holds the HTML page of each UI,
+// named after the script bundle it loads.
+var uiTemplates = template.Must(template.New("swagger-ui-bundle.js").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{ .Title }}</title>
+  <link rel="stylesheet" href="{{ .Prefix }}/swagger-ui.css">
+  <link rel="icon" type="image/png" href="{{ .Prefix }}/favicon-32x32.png" sizes="32x32">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{ .Prefix }}/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({
+      url: {{ .SpecURL }},
+      dom_id: "#swagger-ui",
+      deepLinking: {{ .DeepLinking }},
+      tryItOutEnabled: {{ .TryItOut }},
+      {{- if not .TryItOut }}
+      supportedSubmitMethods: [],
+      {{- end }}
+      presets: [SwaggerUIBundle.presets.apis],
+    });
+  </script>
+</body>
+</html>
+`))